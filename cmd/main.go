@@ -5,17 +5,23 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	tgbot "github.com/tian841224/crawler_sportcenter/internal/bot/tg_bot"
 	"github.com/tian841224/crawler_sportcenter/internal/browser"
+	"github.com/tian841224/crawler_sportcenter/internal/clock"
 	"github.com/tian841224/crawler_sportcenter/internal/crawler"
+	"github.com/tian841224/crawler_sportcenter/internal/domain/availability"
+	"github.com/tian841224/crawler_sportcenter/internal/domain/booking"
 	"github.com/tian841224/crawler_sportcenter/internal/domain/schedule"
+	"github.com/tian841224/crawler_sportcenter/internal/domain/session"
 	timeslot "github.com/tian841224/crawler_sportcenter/internal/domain/time_slot"
 	"github.com/tian841224/crawler_sportcenter/internal/domain/user"
 	"github.com/tian841224/crawler_sportcenter/internal/infrastructure/db"
 	"github.com/tian841224/crawler_sportcenter/internal/scheduler"
 	"github.com/tian841224/crawler_sportcenter/pkg/config"
 	"github.com/tian841224/crawler_sportcenter/pkg/logger"
+	"github.com/tian841224/crawler_sportcenter/pkg/secret"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
@@ -49,10 +55,18 @@ func main() {
 
 	// #region 初始化瀏覽器
 	logger.Log.Info("初始化瀏覽器")
-	browser := browser.NewBrowserService()
+	browser := browser.NewBrowserService(browser.Options{
+		MaxPages:    cfg.BrowserMaxPages,
+		IdleTimeout: time.Duration(cfg.BrowserIdleTimeout) * time.Minute,
+		Headless:    cfg.BrowserHeadless,
+		BinPath:     cfg.BrowserBin,
+		UserDataDir: cfg.BrowserUserDataDir,
+		Proxy:       cfg.BrowserProxy,
+		ControlURL:  cfg.BrowserControlURL,
+		ManagerURL:  cfg.BrowserManagerURL,
+	})
 	defer browser.Close()
 	nantunSportCenterService := crawler.NewNantunSportCenterService(browser)
-	// nantunSportCenterService.CrawlerNantun(cfg)
 	// #endregion
 
 	// #region 初始化 Telegram Bot
@@ -66,9 +80,40 @@ func main() {
 
 	// #region 初始化Repository
 	logger.Log.Info("初始化Repository")
-	userRepository := user.NewUserRepository(&dbInstance)
+	secretBox, err := secret.NewBox(cfg.SecretKey, cfg.PreviousSecretKeys...)
+	if err != nil {
+		logger.Log.Error("加密金鑰設定錯誤，請檢查 SECRET_KEY", zap.Error(err))
+		return
+	}
+	userRepository := user.NewUserRepository(&dbInstance, secretBox)
 	timeslotRepository := timeslot.NewTimeSlotRepository(&dbInstance)
 	scheduleRepository := schedule.NewScheduleRepository(&dbInstance)
+	snapshotRepository := availability.NewSnapshotRepository(&dbInstance)
+	bookingRepository := booking.NewBookingRepository(&dbInstance)
+	var sessionRepository session.Repository
+	if cfg.SessionStore == "memory" {
+		sessionRepository = session.NewMemoryRepository()
+	} else {
+		sessionRepository = session.NewSessionRepository(&dbInstance)
+	}
+	// #endregion
+
+	// 使用 context 控制 Bot 的生命週期
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// #region 初始化網站校時
+	logger.Log.Info("初始化網站校時")
+	serverClock, err := clock.NewServerClock(nantunSportCenterService.Nantun_Url, nil)
+	if err != nil {
+		logger.Log.Error("網站校時設定錯誤", zap.Error(err))
+		return
+	}
+	go func() {
+		if err := serverClock.Sync(ctx); err != nil {
+			logger.Log.Warn("網站校時失敗，使用本機時間", zap.Error(err))
+		}
+	}()
 	// #endregion
 
 	// #region 初始化Service
@@ -76,28 +121,74 @@ func main() {
 	userService := user.NewUserService(userRepository)
 	timeslotService := timeslot.NewTimeSlotService(timeslotRepository)
 	scheduleService := schedule.NewScheduleService(scheduleRepository)
-	nantunSportCenterBotService := crawler.NewNantunSportCenterBotService(browser, nantunSportCenterService, cfg)
+	snapshotService := availability.NewSnapshotService(snapshotRepository)
+	bookingService := booking.NewBookingService(bookingRepository)
+	sessionService := session.NewSessionService(sessionRepository, time.Duration(cfg.SessionTTL)*time.Minute)
+	credentialResolver := crawler.NewUserCredentialResolver(userService, cfg)
+	nantunSportCenterBotService := crawler.NewNantunSportCenterBotService(browser, nantunSportCenterService, credentialResolver)
+	chaoMaSportCenterService := crawler.NewChaoMaSportCenterService(browser, credentialResolver)
+	var nantunProvider crawler.SportCenterProvider = nantunSportCenterBotService
+	if cfg.NantunHTTP {
+		nantunProvider = crawler.NewNantunHTTPService(crawler.NewNantunHTTPClient(credentialResolver, serverClock), nantunProvider)
+	}
+	providers := crawler.NewProviderRegistry(nantunProvider, chaoMaSportCenterService)
 	// #endregion
 
-	handler := tgbot.NewMessageHandler(botService, userService, timeslotService, scheduleService, &nantunSportCenterBotService)
+	callbackCodec, err := tgbot.NewCallbackCodec(cfg.SecretKey)
+	if err != nil {
+		logger.Log.Error("按鈕簽章金鑰設定錯誤", zap.Error(err))
+		return
+	}
+	handler := tgbot.NewMessageHandler(botService, userService, timeslotService, scheduleService, bookingService, sessionService, callbackCodec, providers)
 
 	// 設定訊息處理
 	botService.HandleMessage(handler.HandleUpdate)
 
-	// 使用 context 控制 Bot 的生命週期
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	// 開始接收訊息
-	botService.StartReceiveMessage()
+	// 定期清除過期的對話狀態
+	sessionService.StartCleanup(ctx, time.Duration(cfg.SessionTTL)*time.Minute)
 
 	// #region 初始化Scheduler
 	logger.Log.Info("初始化Scheduler")
-	schedulerService := scheduler.NewSchedulerService(&nantunSportCenterBotService, scheduleService, userService, botService)
+	schedulerService := scheduler.NewSchedulerService(providers, scheduleService, userService, snapshotService, bookingService, botService, serverClock, cfg)
 	schedulerService.Start(ctx)
+	handler.SetCourtReleaseListener(schedulerService)
+	handler.SetClock(serverClock)
 	// #endregion
 
+	// #region 初始化保持登入
+	var keepAlive *scheduler.SessionKeepAlive
+	if cfg.KeepAliveInterval > 0 {
+		logger.Log.Info("初始化保持登入")
+		keepAlive = scheduler.NewSessionKeepAlive(providers, scheduleService, userService, cfg)
+		keepAlive.Start(ctx)
+	}
+	// #endregion
+
+	// #region 初始化付款提醒
+	var paymentWatcher *scheduler.PaymentWatcher
+	if cfg.PaymentCheckInterval > 0 {
+		logger.Log.Info("初始化付款提醒")
+		paymentWatcher = scheduler.NewPaymentWatcher(providers, bookingService, userService, botService, serverClock, cfg)
+		paymentWatcher.Start(ctx)
+	}
+	// #endregion
+
+	// #region 初始化搶場
+	var sniper *crawler.NantunSniper
+	if cfg.SniperEnabled {
+		logger.Log.Info("初始化搶場")
+		sniper, err = crawler.NewNantunSniper(browser, &nantunSportCenterService, serverClock, bookingService, userService, cfg)
+		if err != nil {
+			logger.Log.Error("搶場設定錯誤", zap.Error(err))
+			return
+		}
+		sniper.Start(ctx)
+	}
+	// #endregion
+
+	// 開始接收訊息，需在設定完所有處理元件之後，避免與處理訊息的 goroutine 同時寫入
 	logger.Log.Info("開始接收訊息")
+	botService.StartReceiveMessage()
 
 	// 設定系統信號處理
 	sigChan := make(chan os.Signal, 1)
@@ -109,6 +200,15 @@ func main() {
 
 	// 關閉 scheduler
 	schedulerService.Stop()
+	if keepAlive != nil {
+		keepAlive.Stop()
+	}
+	if paymentWatcher != nil {
+		paymentWatcher.Stop()
+	}
+	if sniper != nil {
+		sniper.Stop()
+	}
 
 	// 關閉瀏覽器
 	if err := browser.Close(); err != nil {
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+
+	"github.com/tian841224/crawler_sportcenter/internal/domain/user"
+	"github.com/tian841224/crawler_sportcenter/internal/infrastructure/db"
+	"github.com/tian841224/crawler_sportcenter/pkg/config"
+	"github.com/tian841224/crawler_sportcenter/pkg/logger"
+	"github.com/tian841224/crawler_sportcenter/pkg/secret"
+	"go.uber.org/zap"
+)
+
+// 將明文或舊金鑰加密的運動中心密碼以 SECRET_KEY 重新加密
+// 輪替金鑰時將舊金鑰填入 SECRET_KEY_PREVIOUS，執行完成後即可移除
+func main() {
+	logger.InitLogger()
+	cfg := config.LoadConfig()
+
+	secretBox, err := secret.NewBox(cfg.SecretKey, cfg.PreviousSecretKeys...)
+	if err != nil {
+		logger.Log.Error("加密金鑰設定錯誤，請檢查 SECRET_KEY", zap.Error(err))
+		return
+	}
+
+	dbInstance, err := db.NewDatabase(cfg)
+	if err != nil {
+		logger.Log.Error("資料庫初始化失敗", zap.Error(err))
+		return
+	}
+	defer dbInstance.Close()
+
+	userRepository := user.NewUserRepository(&dbInstance, secretBox)
+	if userRepository == nil {
+		logger.Log.Error("初始化 UserRepository 失敗")
+		return
+	}
+
+	count, err := userRepository.ReencryptPasswords(context.Background())
+	if err != nil {
+		logger.Log.Error("重新加密密碼失敗", zap.Int("updated", count), zap.Error(err))
+		return
+	}
+
+	logger.Log.Info("重新加密密碼完成", zap.Int("updated", count))
+}
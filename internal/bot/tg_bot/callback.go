@@ -0,0 +1,153 @@
+package tgbot
+
+import (
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tian841224/crawler_sportcenter/internal/types"
+)
+
+// 按鈕回傳資料格式：版本|動作|場館|星期|時段|參數|簽章
+// 簽章另包含聊天室 ID，按鈕無法在其他聊天室重送
+const (
+	callbackVersion   = "2"
+	callbackSeparator = "|"
+	callbackMaxLength = 64 // Telegram callback_data 上限
+	signatureLength   = 6  // HMAC 截取長度（bytes）
+	callbackKeyInfo   = "tgbot callback signature"
+)
+
+// 按鈕動作
+const (
+	actionBackToMain = "m"
+	actionVenue      = "v"
+	actionDate       = "d"
+	actionDateInput  = "di" // 輸入指定日期
+	actionTimeSlot   = "t"
+	actionCheckNow   = "c"
+	actionSubscribe  = "s"
+	actionBook       = "b"
+
+	// 訂閱管理，參數為排程 ID
+	actionSubList          = "sl"
+	actionSubDelete        = "sd"
+	actionSubDeleteConfirm = "sy"
+	actionSubPause         = "sp"
+	actionSubExpiry        = "se"
+	actionSubAutoBook      = "sa"
+
+	// 預約管理，參數為預約紀錄 ID
+	actionBookingCancel        = "bc"
+	actionBookingCancelConfirm = "by"
+)
+
+var (
+	ErrCallbackVersion   = errors.New("按鈕版本不符")
+	ErrCallbackSignature = errors.New("按鈕簽章錯誤")
+	ErrCallbackFormat    = errors.New("按鈕資料格式錯誤")
+	ErrCallbackTooLong   = errors.New("按鈕資料超過 64 bytes")
+)
+
+// CallbackData 按鈕回傳資料，包含處理該按鈕所需的完整選擇內容
+type CallbackData struct {
+	Action   string
+	Venue    types.VenueID
+	Weekday  time.Weekday
+	TimeSlot int    // 0 表示未選擇
+	Arg      string // 動作參數，例如預約按鈕、指定日期
+}
+
+// CallbackCodec 編碼與驗證按鈕回傳資料
+type CallbackCodec struct {
+	key []byte
+}
+
+// NewCallbackCodec 由主金鑰衍生出簽章專用的金鑰，不直接使用加密密碼的金鑰
+func NewCallbackCodec(secret string) (*CallbackCodec, error) {
+	key, err := hkdf.Key(sha256.New, []byte(secret), nil, callbackKeyInfo, sha256.Size)
+	if err != nil {
+		return nil, fmt.Errorf("derive callback key: %w", err)
+	}
+	return &CallbackCodec{key: key}, nil
+}
+
+// Encode 將按鈕資料編碼並以聊天室 ID 加上簽章
+func (c *CallbackCodec) Encode(chatID int64, data CallbackData) (string, error) {
+	if strings.Contains(data.Arg, callbackSeparator) || strings.Contains(string(data.Venue), callbackSeparator) {
+		return "", ErrCallbackFormat
+	}
+
+	timeSlot := ""
+	if data.TimeSlot > 0 {
+		timeSlot = strconv.Itoa(data.TimeSlot)
+	}
+
+	payload := strings.Join([]string{
+		callbackVersion,
+		data.Action,
+		string(data.Venue),
+		strconv.Itoa(int(data.Weekday)),
+		timeSlot,
+		data.Arg,
+	}, callbackSeparator)
+
+	encoded := payload + callbackSeparator + c.sign(chatID, payload)
+	if len(encoded) > callbackMaxLength {
+		return "", fmt.Errorf("%w: %s", ErrCallbackTooLong, encoded)
+	}
+	return encoded, nil
+}
+
+// Decode 以按下按鈕的聊天室 ID 驗證簽章並解析按鈕資料
+func (c *CallbackCodec) Decode(chatID int64, encoded string) (CallbackData, error) {
+	index := strings.LastIndex(encoded, callbackSeparator)
+	if index == -1 {
+		return CallbackData{}, ErrCallbackFormat
+	}
+
+	payload, signature := encoded[:index], encoded[index+1:]
+	if !hmac.Equal([]byte(signature), []byte(c.sign(chatID, payload))) {
+		return CallbackData{}, ErrCallbackSignature
+	}
+
+	fields := strings.Split(payload, callbackSeparator)
+	if len(fields) != 6 {
+		return CallbackData{}, ErrCallbackFormat
+	}
+	if fields[0] != callbackVersion {
+		return CallbackData{}, ErrCallbackVersion
+	}
+
+	weekday, err := strconv.Atoi(fields[3])
+	if err != nil || weekday < 0 || weekday > 6 {
+		return CallbackData{}, ErrCallbackFormat
+	}
+
+	timeSlot := 0
+	if fields[4] != "" {
+		if timeSlot, err = strconv.Atoi(fields[4]); err != nil {
+			return CallbackData{}, ErrCallbackFormat
+		}
+	}
+
+	return CallbackData{
+		Action:   fields[1],
+		Venue:    types.VenueID(fields[2]),
+		Weekday:  time.Weekday(weekday),
+		TimeSlot: timeSlot,
+		Arg:      fields[5],
+	}, nil
+}
+
+func (c *CallbackCodec) sign(chatID int64, payload string) string {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write([]byte(strconv.FormatInt(chatID, 10) + callbackSeparator + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:signatureLength])
+}
@@ -0,0 +1,56 @@
+package tgbot
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tian841224/crawler_sportcenter/internal/types"
+)
+
+func TestCallbackCodecRoundTrip(t *testing.T) {
+	codec, err := NewCallbackCodec("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := CallbackData{Action: actionTimeSlot, Venue: types.VenueNantun, Weekday: time.Tuesday, TimeSlot: 18, Arg: "2026-11-03"}
+	encoded, err := codec.Encode(42, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := codec.Decode(42, encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded != data {
+		t.Errorf("decoded = %+v, want %+v", decoded, data)
+	}
+}
+
+func TestCallbackCodecRejectsOtherChat(t *testing.T) {
+	codec, err := NewCallbackCodec("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encoded, err := codec.Encode(42, CallbackData{Action: actionSubDeleteConfirm, Arg: "7"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := codec.Decode(43, encoded); !errors.Is(err, ErrCallbackSignature) {
+		t.Errorf("decode from other chat: err = %v, want %v", err, ErrCallbackSignature)
+	}
+}
+
+func TestCallbackCodecKeyIsDerived(t *testing.T) {
+	codec, err := NewCallbackCodec("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(codec.key) == "secret" {
+		t.Error("callback key must not reuse the encryption key")
+	}
+}
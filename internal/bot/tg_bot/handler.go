@@ -9,8 +9,11 @@ import (
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/tian841224/crawler_sportcenter/internal/clock"
 	"github.com/tian841224/crawler_sportcenter/internal/crawler"
+	"github.com/tian841224/crawler_sportcenter/internal/domain/booking"
 	"github.com/tian841224/crawler_sportcenter/internal/domain/schedule"
+	"github.com/tian841224/crawler_sportcenter/internal/domain/session"
 	timeslot "github.com/tian841224/crawler_sportcenter/internal/domain/time_slot"
 	"github.com/tian841224/crawler_sportcenter/internal/domain/user"
 	"github.com/tian841224/crawler_sportcenter/internal/types"
@@ -20,37 +23,55 @@ import (
 )
 
 type MessageHandler struct {
-	bot                     TGBotInterface
-	nantun_sport            crawler.NantunSportCenterBotInterface
-	user                    user.Service
-	timeslot                timeslot.Service
-	schedule                schedule.Service
-	userSelectionDate       string
-	userSelectionWeekday    time.Weekday
-	userSelectionTimeSlotID uint
-	userSelectionTimeSlot   string
-	settingState            map[int64]string // 新增：用於追蹤使用者的設定狀態
-}
-
-func NewMessageHandler(bot TGBotInterface, user user.Service, timeslot timeslot.Service, schedule schedule.Service, nantun_sport crawler.NantunSportCenterBotInterface) *MessageHandler {
+	bot       TGBotInterface
+	providers *crawler.ProviderRegistry
+	user      user.Service
+	timeslot  timeslot.Service
+	schedule  schedule.Service
+	booking   booking.Service
+	session   session.Service // 每個聊天室的文字輸入流程狀態
+	codec     *CallbackCodec  // 按鈕資料編碼，選擇內容皆記錄在按鈕中
+	clock     clock.Clock     // 以網站時間判斷日期
+	released  CourtReleaseListener
+}
+
+// CourtReleaseListener 場地因取消而釋出時通知，讓其他訂閱者盡快得知
+type CourtReleaseListener interface {
+	CourtReleased(venueID types.VenueID, date time.Time)
+}
+
+func NewMessageHandler(bot TGBotInterface, user user.Service, timeslot timeslot.Service, schedule schedule.Service, booking booking.Service, session session.Service, codec *CallbackCodec, providers *crawler.ProviderRegistry) *MessageHandler {
 	return &MessageHandler{
-		bot:          bot,
-		nantun_sport: nantun_sport,
-		user:         user,
-		timeslot:     timeslot,
-		schedule:     schedule,
-		settingState: make(map[int64]string), // 初始化 settingState
+		bot:       bot,
+		providers: providers,
+		user:      user,
+		timeslot:  timeslot,
+		schedule:  schedule,
+		booking:   booking,
+		session:   session,
+		codec:     codec,
+		clock:     clock.SystemClock{},
 	}
 }
 
+// SetClock 設定判斷日期用的時鐘，預設為本機時間
+func (h *MessageHandler) SetClock(clk clock.Clock) {
+	h.clock = clk
+}
+
+// SetCourtReleaseListener 設定取消預約後要通知的對象
+func (h *MessageHandler) SetCourtReleaseListener(listener CourtReleaseListener) {
+	h.released = listener
+}
+
 // HandleUpdate 處理所有的更新消息
 func (h *MessageHandler) HandleUpdate(update tgbotapi.Update) {
 	switch {
 	case update.Message != nil:
 		h.handleMessage(update.Message)
 	case update.CallbackQuery != nil:
-		// 取TG ID
-		id := update.CallbackQuery.From.ID
+		// 一律以聊天室 ID 識別使用者，與對話狀態及爬蟲標籤一致
+		id := update.CallbackQuery.Message.Chat.ID
 		_, err := h.getOrCreateUser(id)
 		if err != nil {
 			logger.Log.Error("get or create user", zap.Error(err))
@@ -64,16 +85,34 @@ func (h *MessageHandler) HandleUpdate(update tgbotapi.Update) {
 // #region 處理所有格式訊息
 // 處理文字訊息
 func (h *MessageHandler) handleMessage(message *tgbotapi.Message) {
+	sess, err := h.session.Get(context.Background(), message.Chat.ID)
+	if err != nil {
+		logger.Log.Error("get session", zap.Error(err))
+		return
+	}
+
 	// 檢查是否在設定流程中
-	if state, exists := h.settingState[message.From.ID]; exists {
-		switch state {
-		case "waiting_account":
-			h.handleAccountInput(message)
-			h.settingState[message.From.ID] = "waiting_password"
-		case "waiting_password":
-			h.handlePasswordInput(message)
-			delete(h.settingState, message.From.ID) // 清除設定狀態
-		}
+	switch sess.State {
+	case stateWaitingAccount:
+		h.handleAccountInput(message, sess)
+		return
+	case stateWaitingPassword:
+		h.handlePasswordInput(message, sess)
+		return
+	case stateWaitingDate:
+		h.handleDateInput(message, sess)
+		return
+	case stateWaitingExpiry:
+		h.handleExpiryInput(message, sess)
+		return
+	case stateWaitingPreference:
+		h.handlePreferenceInput(message, sess)
+		return
+	}
+
+	// 帶參數的命令
+	if command, args, _ := strings.Cut(message.Text, " "); command == "/autobook" {
+		h.handleAutoBookCommand(message, strings.Fields(args))
 		return
 	}
 
@@ -83,44 +122,106 @@ func (h *MessageHandler) handleMessage(message *tgbotapi.Message) {
 		h.handleStart(message)
 	case "/setting":
 		h.handleSetting(message)
+	case "/preference":
+		h.handlePreference(message)
+	case "/subscriptions", "/list", "/unsubscribe":
+		h.handleSubscriptions(message.Chat.ID)
+	case "/mybookings":
+		h.handleMyBookings(message.Chat.ID)
+	case "/pause":
+		h.handleUserStatus(message, false)
+	case "/resume":
+		h.handleUserStatus(message, true)
 	default:
 		h.handleDefault(message)
 	}
 }
 
-// 處理按鈕回饋
+// 文字輸入流程狀態
 const (
-	callbackNantunSport = "nantun_sport"
-	callbackBackToMain  = "back_to_main"
-	prefixDate          = "date_"
-	prefixTimeSlot      = "time_slot_"
-	prefixBook          = "book_"
-	prefixSubWeedDay    = "sub_weed_day_"
-	prefixSubTimeSlot   = "sub_time_slot_"
+	stateWaitingAccount    = "waiting_account"
+	stateWaitingPassword   = "waiting_password"
+	stateWaitingDate       = "waiting_date"
+	stateWaitingExpiry     = "waiting_expiry"
+	stateWaitingPreference = "waiting_preference"
 )
 
+// 處理按鈕回饋
 func (h *MessageHandler) handleCallback(callback *tgbotapi.CallbackQuery) {
-	switch {
-	// 南屯運動中心
-	case callback.Data == callbackNantunSport:
+	data, err := h.codec.Decode(callback.Message.Chat.ID, callback.Data)
+	if err != nil {
+		// 舊版或遭竄改的按鈕，請使用者重新選擇
+		logger.Log.Warn("invalid callback data", zap.String("data", callback.Data), zap.Error(err))
+		h.bot.Request(tgbotapi.NewCallback(callback.ID, "按鈕已失效"))
+		h.handleBackToMain(callback)
+		return
+	}
+
+	switch data.Action {
+	// 運動中心選擇
+	case actionVenue:
 		h.bot.Request(tgbotapi.NewCallback(callback.ID, ""))
-		h.handleSportCenterSelection(callback)
+		h.handleSportCenterSelection(callback, data)
 	// 返回主選單
-	case callback.Data == callbackBackToMain:
+	case actionBackToMain:
 		h.bot.Request(tgbotapi.NewCallback(callback.ID, ""))
 		h.handleBackToMain(callback)
 	// 日期選擇
-	case strings.HasPrefix(callback.Data, prefixDate):
+	case actionDate:
 		h.bot.Request(tgbotapi.NewCallback(callback.ID, ""))
-		h.handleDateSelection(callback)
+		h.handleDateSelection(callback, data)
+	// 指定日期
+	case actionDateInput:
+		h.bot.Request(tgbotapi.NewCallback(callback.ID, ""))
+		h.handleDateInputStart(callback, data)
 	// 時段選擇
-	case strings.HasPrefix(callback.Data, prefixTimeSlot):
+	case actionTimeSlot:
+		h.bot.Request(tgbotapi.NewCallback(callback.ID, ""))
+		h.handleTimeSlotSelection(callback, data)
+	// 立即查詢
+	case actionCheckNow:
 		h.bot.Request(tgbotapi.NewCallback(callback.ID, ""))
-		h.handleTimeSlotSelection(callback)
+		h.handleCheckNow(callback, data)
+	// 訂閱時段
+	case actionSubscribe:
+		h.bot.Request(tgbotapi.NewCallback(callback.ID, ""))
+		h.handleSubscribe(callback, data)
 	// 預約場地
-	case strings.HasPrefix(callback.Data, prefixBook):
+	case actionBook:
+		h.bot.Request(tgbotapi.NewCallback(callback.ID, ""))
+		h.handleBooking(callback, data)
+	// 訂閱列表
+	case actionSubList:
+		h.bot.Request(tgbotapi.NewCallback(callback.ID, ""))
+		h.handleSubscriptions(callback.Message.Chat.ID)
+	// 刪除訂閱（確認）
+	case actionSubDelete:
+		h.bot.Request(tgbotapi.NewCallback(callback.ID, ""))
+		h.handleSubscriptionDelete(callback, data)
+	// 刪除訂閱
+	case actionSubDeleteConfirm:
+		h.bot.Request(tgbotapi.NewCallback(callback.ID, ""))
+		h.handleSubscriptionDeleteConfirm(callback, data)
+	// 暫停/恢復訂閱
+	case actionSubPause:
 		h.bot.Request(tgbotapi.NewCallback(callback.ID, ""))
-		h.handleBooking(callback)
+		h.handleSubscriptionPause(callback, data)
+	// 設定訂閱期限
+	case actionSubExpiry:
+		h.bot.Request(tgbotapi.NewCallback(callback.ID, ""))
+		h.handleSubscriptionExpiry(callback, data)
+	// 開關自動預約
+	case actionSubAutoBook:
+		h.bot.Request(tgbotapi.NewCallback(callback.ID, ""))
+		h.handleSubscriptionAutoBook(callback, data)
+	// 取消預約（確認）
+	case actionBookingCancel:
+		h.bot.Request(tgbotapi.NewCallback(callback.ID, ""))
+		h.handleBookingCancel(callback, data)
+	// 取消預約
+	case actionBookingCancelConfirm:
+		h.bot.Request(tgbotapi.NewCallback(callback.ID, ""))
+		h.handleBookingCancelConfirm(callback, data)
 	default:
 		h.handleUnknownCallback(callback)
 	}
@@ -133,154 +234,217 @@ func (h *MessageHandler) handleUnknownCallback(callback *tgbotapi.CallbackQuery)
 
 func (h *MessageHandler) handleBackToMain(callback *tgbotapi.CallbackQuery) {
 	text := "請選擇您要查詢的場地"
-	keyboard := tgbotapi.NewInlineKeyboardMarkup(
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("南屯運動中心", "nantun_sport"),
-		),
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("朝馬運動中心", "chao_ma_sport"),
-		),
-	)
+	keyboard := h.createVenueKeyboard(callback.Message.Chat.ID)
 	h.bot.SendeKeyboardMessage(callback.Message.Chat.ID, text, keyboard)
 }
 
+// 取得使用者按下的按鈕文字
+func pressedButtonText(callback *tgbotapi.CallbackQuery) string {
+	if callback.Message == nil || callback.Message.ReplyMarkup == nil {
+		return ""
+	}
+	for _, row := range callback.Message.ReplyMarkup.InlineKeyboard {
+		for _, button := range row {
+			if button.CallbackData != nil && *button.CallbackData == callback.Data {
+				return button.Text
+			}
+		}
+	}
+	return ""
+}
+
+// 建立按鈕，編碼失敗時改為返回主選單
+func (h *MessageHandler) newButton(chatID int64, text string, data CallbackData) tgbotapi.InlineKeyboardButton {
+	encoded, err := h.codec.Encode(chatID, data)
+	if err != nil {
+		logger.Log.Error("encode callback data", zap.Error(err))
+		encoded, _ = h.codec.Encode(chatID, CallbackData{Action: actionBackToMain})
+	}
+	return tgbotapi.NewInlineKeyboardButtonData(text, encoded)
+}
+
+// 建立返回主選單按鈕列
+func (h *MessageHandler) backToMainRow(chatID int64) []tgbotapi.InlineKeyboardButton {
+	return tgbotapi.NewInlineKeyboardRow(
+		h.newButton(chatID, "返回主選單", CallbackData{Action: actionBackToMain}),
+	)
+}
+
+// 建立運動中心選擇鍵盤
+func (h *MessageHandler) createVenueKeyboard(chatID int64) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, provider := range h.providers.List() {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			h.newButton(chatID, provider.Name(), CallbackData{Action: actionVenue, Venue: provider.ID()}),
+		))
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
 // 處理運動中心選擇
-func (h *MessageHandler) handleSportCenterSelection(callback *tgbotapi.CallbackQuery) {
+func (h *MessageHandler) handleSportCenterSelection(callback *tgbotapi.CallbackQuery, data CallbackData) {
+	if _, err := h.providers.Get(data.Venue); err != nil {
+		logger.Log.Error("invalid venue", zap.String("venue", string(data.Venue)), zap.Error(err))
+		h.handleUnknownCallback(callback)
+		return
+	}
+
 	text := "選擇訂閱時間"
-	keyboard := h.createDateSelectionKeyboard()
+	keyboard := h.createDateSelectionKeyboard(callback.Message.Chat.ID, data.Venue)
 	h.bot.SendeKeyboardMessage(callback.Message.Chat.ID, text, keyboard)
 }
 
 // 建立日期選擇鍵盤
-func (h *MessageHandler) createDateSelectionKeyboard() tgbotapi.InlineKeyboardMarkup {
+func (h *MessageHandler) createDateSelectionKeyboard(chatID int64, venue types.VenueID) tgbotapi.InlineKeyboardMarkup {
+	dayButton := func(weekday time.Weekday) tgbotapi.InlineKeyboardButton {
+		return h.newButton(chatID, types.WeekdayName(weekday), CallbackData{Action: actionDate, Venue: venue, Weekday: weekday})
+	}
+
 	return tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("日", "date_0"),
+			dayButton(time.Sunday),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("一", "date_1"),
-			tgbotapi.NewInlineKeyboardButtonData("二", "date_2"),
-			tgbotapi.NewInlineKeyboardButtonData("三", "date_3"),
+			dayButton(time.Monday),
+			dayButton(time.Tuesday),
+			dayButton(time.Wednesday),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("四", "date_4"),
-			tgbotapi.NewInlineKeyboardButtonData("五", "date_5"),
-			tgbotapi.NewInlineKeyboardButtonData("六", "date_6"),
+			dayButton(time.Thursday),
+			dayButton(time.Friday),
+			dayButton(time.Saturday),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("返回主選單", "back_to_main"),
+			h.newButton(chatID, "指定日期", CallbackData{Action: actionDateInput, Venue: venue}),
 		),
+		h.backToMainRow(chatID),
 	)
 }
 
 // 處理日期選擇
-func (h *MessageHandler) handleDateSelection(callback *tgbotapi.CallbackQuery) {
-	dayMap := map[string]string{
-		"0": "日", "1": "一", "2": "二",
-		"3": "三", "4": "四", "5": "五", "6": "六",
-	}
-	h.userSelectionDate = dayMap[callback.Data[5:]]
+func (h *MessageHandler) handleDateSelection(callback *tgbotapi.CallbackQuery, data CallbackData) {
+	logger.Log.Info("收到按鈕回調：" + types.WeekdayName(data.Weekday))
 
-	weekdayInt, err := strconv.Atoi(callback.Data[5:])
+	text := "選擇訂閱時間"
+	keyboard := h.createTimeSlotKeyboard(callback.Message.Chat.ID, data)
+	h.bot.SendeKeyboardMessage(callback.Message.Chat.ID, text, keyboard)
+}
+
+// 請使用者輸入指定日期
+func (h *MessageHandler) handleDateInputStart(callback *tgbotapi.CallbackQuery, data CallbackData) {
+	sess, err := h.session.Get(context.Background(), callback.Message.Chat.ID)
 	if err != nil {
-		logger.Log.Error("invalid weekday", zap.String("weekday", callback.Data[5:]), zap.Error(err))
+		logger.Log.Error("get session", zap.Error(err))
+		return
+	}
+	sess.State = stateWaitingDate
+	sess.VenueID = string(data.Venue)
+	if err := h.session.Save(context.Background(), sess); err != nil {
+		logger.Log.Error("save session", zap.Error(err))
 		return
 	}
-	h.userSelectionWeekday = time.Weekday(weekdayInt)
-	logger.Log.Info("收到按鈕回調：" + h.userSelectionDate)
 
-	text := "選擇訂閱時間"
-	keyboard := h.createTimeSlotKeyboard()
-	h.bot.SendeKeyboardMessage(callback.Message.Chat.ID, text, keyboard)
+	h.bot.SendMessage(callback.Message.Chat.ID, "請輸入日期，例如 2026-11-03：")
 }
 
-func (h *MessageHandler) createTimeSlotKeyboard() tgbotapi.InlineKeyboardMarkup {
-	timeSlots := []struct {
-		Text string
-		Data string
-	}{
-		{Text: "6:00-7:00", Data: "time_slot_1"},
-		{"7:00-8:00", "time_slot_2"},
-		{"8:00-9:00", "time_slot_3"},
-		{"9:00-10:00", "time_slot_4"},
-		{"10:00-11:00", "time_slot_5"},
-		{"11:00-12:00", "time_slot_6"},
-		{"12:00-13:00", "time_slot_7"},
-		{"13:00-14:00", "time_slot_8"},
-		{"14:00-15:00", "time_slot_9"},
-		{"15:00-16:00", "time_slot_10"},
-		{"16:00-17:00", "time_slot_11"},
-		{"17:00-18:00", "time_slot_12"},
-		{"18:00-19:00", "time_slot_13"},
-		{"19:00-20:00", "time_slot_14"},
-		{"20:00-21:00", "time_slot_15"},
-		{"21:00-22:00", "time_slot_16"},
+// 處理指定日期輸入
+func (h *MessageHandler) handleDateInput(message *tgbotapi.Message, sess *session.Session) {
+	date, err := types.ParseDate(message.Text)
+	if err != nil {
+		h.bot.SendMessage(message.Chat.ID, "日期格式錯誤，請輸入例如 2026-11-03：")
+		return
+	}
+	if date.Before(types.DateOnly(h.clock.Now())) {
+		h.bot.SendMessage(message.Chat.ID, "不能選擇已過去的日期，請重新輸入：")
+		return
 	}
 
+	venue := types.VenueID(sess.VenueID)
+	if err := h.session.Clear(context.Background(), message.Chat.ID); err != nil {
+		logger.Log.Error("clear session", zap.Error(err))
+	}
+
+	text := fmt.Sprintf("%s（星期%s），選擇訂閱時間", date.Format(types.DateLayout), types.WeekdayName(date.Weekday()))
+	keyboard := h.createTimeSlotKeyboard(message.Chat.ID, CallbackData{Venue: venue, Weekday: date.Weekday(), Arg: date.Format(types.DateLayout)})
+	h.bot.SendeKeyboardMessage(message.Chat.ID, text, keyboard)
+}
+
+func (h *MessageHandler) createTimeSlotKeyboard(chatID int64, data CallbackData) tgbotapi.InlineKeyboardMarkup {
 	var rows [][]tgbotapi.InlineKeyboardButton
 	// 每行放置3個按鈕
-	for i := 0; i < len(timeSlots); i += 3 {
+	for code := types.TimeSlot_6_7; code <= types.TimeSlot_21_22; code += 3 {
 		var row []tgbotapi.InlineKeyboardButton
-		for j := 0; j < 3 && i+j < len(timeSlots); j++ {
-			slot := timeSlots[i+j]
-			row = append(row, tgbotapi.NewInlineKeyboardButtonData(slot.Text, slot.Data))
+		for j := types.TimeSlotCode(0); j < 3 && code+j <= types.TimeSlot_21_22; j++ {
+			slot := code + j
+			text := fmt.Sprintf("%d:00-%d:00", slot.StartHour(), slot.StartHour()+1)
+			row = append(row, h.newButton(chatID, text, CallbackData{
+				Action:   actionTimeSlot,
+				Venue:    data.Venue,
+				Weekday:  data.Weekday,
+				TimeSlot: int(slot),
+				Arg:      data.Arg,
+			}))
 		}
 		rows = append(rows, row)
 	}
 
-	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
-		tgbotapi.NewInlineKeyboardButtonData("返回主選單", "back_to_main"),
-	))
+	rows = append(rows, h.backToMainRow(chatID))
 
 	return tgbotapi.NewInlineKeyboardMarkup(rows...)
 }
 
-// 處理時段選擇
-func (h *MessageHandler) handleTimeSlotSelection(callback *tgbotapi.CallbackQuery) {
-	h.userSelectionTimeSlot = callback.Data[10:]
-	num, _ := strconv.Atoi(h.userSelectionTimeSlot)
-	timeSlotIDInt, err := strconv.Atoi(h.userSelectionTimeSlot)
-	if err != nil {
-		logger.Log.Error("invalid time slot", zap.String("time slot", h.userSelectionTimeSlot), zap.Error(err))
-		return
-	}
-	timeSlotID := uint(timeSlotIDInt)
+// 處理時段選擇，讓使用者選擇立即查詢或訂閱
+func (h *MessageHandler) handleTimeSlotSelection(callback *tgbotapi.CallbackQuery, data CallbackData) {
+	logger.Log.Info(fmt.Sprintf("User selected time slot: %d", data.TimeSlot))
 
-	userObj, err := h.user.GetByAccountID(context.Background(), fmt.Sprintf("%d", callback.Message.Chat.ID))
-	if err != nil {
-		logger.Log.Error("get user by id", zap.Error(err))
+	code := types.TimeSlotCode(data.TimeSlot)
+	next := data
+
+	// 指定日期只提供訂閱，立即查詢僅能查詢最近的星期
+	if data.Arg != "" {
+		next.Action = actionSubscribe
+		text := fmt.Sprintf("%s %d:00-%d:00，請選擇：", data.Arg, code.StartHour(), code.StartHour()+1)
+		keyboard := tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(h.newButton(callback.Message.Chat.ID, "訂閱此日期", next)),
+			h.backToMainRow(callback.Message.Chat.ID),
+		)
+		h.bot.SendeKeyboardMessage(callback.Message.Chat.ID, text, keyboard)
 		return
 	}
-	if userObj == nil {
-		err = h.user.Create(context.Background(), &user.User{
-			AccountID: fmt.Sprintf("%d", callback.Message.Chat.ID),
-			Status:    true,
-		})
-		if err != nil {
-			logger.Log.Error("create user", zap.Error(err))
-			return
-		}
-	}
 
-	err = h.schedule.Create(context.Background(), &schedule.Schedule{
-		UserID:     userObj.ID,
-		Weekday:    h.userSelectionWeekday,
-		TimeSlotID: &timeSlotID,
-	})
+	next.Action = actionCheckNow
+	checkButton := h.newButton(callback.Message.Chat.ID, "立即查詢", next)
+	next.Action = actionSubscribe
+	subscribeButton := h.newButton(callback.Message.Chat.ID, "訂閱此時段", next)
 
+	text := fmt.Sprintf("星期%s %d:00-%d:00，請選擇：", types.WeekdayName(data.Weekday), code.StartHour(), code.StartHour()+1)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(checkButton, subscribeButton),
+		h.backToMainRow(callback.Message.Chat.ID),
+	)
+	h.bot.SendeKeyboardMessage(callback.Message.Chat.ID, text, keyboard)
+}
+
+// 立即查詢可預約場地，不建立訂閱
+func (h *MessageHandler) handleCheckNow(callback *tgbotapi.CallbackQuery, data CallbackData) {
+	provider, err := h.providers.Get(data.Venue)
 	if err != nil {
-		logger.Log.Error("create schedule", zap.Error(err))
+		logger.Log.Error("get provider", zap.Error(err))
 		return
 	}
 
-	logger.Log.Info("User selected time slot: " + h.userSelectionTimeSlot)
-
-	availableSlots, err := h.nantun_sport.GetAvailableTimeSlots(h.userSelectionDate, num, fmt.Sprint(callback.Message.Chat.ID))
+	availableSlots, err := provider.GetAvailableTimeSlots(clock.NextDateByWeekday(h.clock, data.Weekday), data.TimeSlot, fmt.Sprint(callback.Message.Chat.ID))
 	if err != nil {
 		logger.Log.Error(err.Error())
+		h.bot.SendMessage(callback.Message.Chat.ID, fmt.Sprintf("查詢失敗：%v", err))
 		return
 	}
 
+	// 依使用者的場地偏好排序，並隱藏不預約的場地
+	if userObj, err := h.getOrCreateUser(callback.Message.Chat.ID); err == nil {
+		availableSlots = types.ParseCourtPreference(userObj.CourtPreference).Apply(availableSlots)
+	}
+
 	if len(availableSlots) == 0 {
 		text := "目前無場地可預約，請重新選擇"
 		h.bot.SendMessage(callback.Message.Chat.ID, text)
@@ -289,36 +453,126 @@ func (h *MessageHandler) handleTimeSlotSelection(callback *tgbotapi.CallbackQuer
 
 	var keyboardRows [][]tgbotapi.InlineKeyboardButton
 	for _, slot := range availableSlots {
+		encoded, err := h.codec.Encode(callback.Message.Chat.ID, CallbackData{
+			Action:   actionBook,
+			Venue:    data.Venue,
+			Weekday:  data.Weekday,
+			TimeSlot: data.TimeSlot,
+			Arg:      slot.Params.String(),
+		})
+		if err != nil {
+			logger.Log.Error("encode booking button", zap.String("court", slot.CourtName), zap.Error(err))
+			continue
+		}
 		row := tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData(slot.CourtName, "book_"+slot.Button),
+			tgbotapi.NewInlineKeyboardButtonData(slot.CourtName, encoded),
 		)
 		keyboardRows = append(keyboardRows, row)
 	}
 
-	backRow := tgbotapi.NewInlineKeyboardRow(
-		tgbotapi.NewInlineKeyboardButtonData("返回主選單", "back_to_main"),
-	)
-	keyboardRows = append(keyboardRows, backRow)
+	keyboardRows = append(keyboardRows, h.backToMainRow(callback.Message.Chat.ID))
 
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(keyboardRows...)
 	text := "以下是可預約的場地："
 	h.bot.SendeKeyboardMessage(callback.Message.Chat.ID, text, keyboard)
 }
 
-func (h *MessageHandler) handleBooking(callback *tgbotapi.CallbackQuery) error {
-	selectedCourt := callback.Data[5:]
-	logger.Log.Info("使用者嘗試預約場地：" + selectedCourt)
+// 訂閱時段，由排程通知是否有空場地
+func (h *MessageHandler) handleSubscribe(callback *tgbotapi.CallbackQuery, data CallbackData) {
+	timeSlotID := uint(data.TimeSlot)
+
+	provider, err := h.providers.Get(data.Venue)
+	if err != nil {
+		logger.Log.Error("get provider", zap.Error(err))
+		return
+	}
+
+	userObj, err := h.getOrCreateUser(callback.Message.Chat.ID)
+	if err != nil {
+		logger.Log.Error("get or create user", zap.Error(err))
+		return
+	}
+
+	subs := &schedule.Schedule{
+		UserID:     userObj.ID,
+		VenueID:    string(provider.ID()),
+		Weekday:    data.Weekday,
+		TimeSlotID: &timeSlotID,
+	}
+
+	// 指定日期的訂閱
+	if data.Arg != "" {
+		date, err := types.ParseDate(data.Arg)
+		if err != nil {
+			logger.Log.Error("parse subscribe date", zap.String("date", data.Arg), zap.Error(err))
+			h.handleUnknownCallback(callback)
+			return
+		}
+		subs.Date = &date
+		subs.Weekday = date.Weekday()
+	}
+	if err := h.schedule.Create(context.Background(), subs); err != nil {
+		if errors.Is(err, schedule.ErrDuplicateSchedule) {
+			h.bot.SendMessage(callback.Message.Chat.ID, "您已訂閱相同時段，可使用 /subscriptions 查看")
+			return
+		}
+		logger.Log.Error("create schedule", zap.Error(err))
+		h.bot.SendMessage(callback.Message.Chat.ID, "訂閱失敗，請稍後再試")
+		return
+	}
 
-	targetSlot := []types.CleanTimeSlot{{Button: selectedCourt}}
-	if err := h.nantun_sport.BookCourt(targetSlot); err != nil {
+	if timeSlot, err := h.timeslot.GetByID(context.Background(), timeSlotID); err == nil {
+		subs.TimeSlot = timeSlot
+	}
+
+	text := fmt.Sprintf("訂閱成功：%s\n有空場地時會通知您，可使用 /subscriptions 管理訂閱", h.formatSchedule(subs))
+	h.bot.SendMessage(callback.Message.Chat.ID, text)
+}
+
+func (h *MessageHandler) handleBooking(callback *tgbotapi.CallbackQuery, data CallbackData) error {
+	logger.Log.Info("使用者嘗試預約場地：" + data.Arg)
+
+	params, err := types.ParseBookingParams(data.Arg)
+	if err != nil {
+		logger.Log.Error("parse booking params", zap.Error(err))
+		h.handleUnknownCallback(callback)
+		return err
+	}
+
+	provider, err := h.providers.Get(data.Venue)
+	if err != nil {
+		logger.Log.Error("get provider", zap.Error(err))
+		return err
+	}
+
+	// 場地名稱取自使用者按下的按鈕，時間依選擇的時段
+	start, end := types.TimeSlotCode(data.TimeSlot).On(params.Date)
+	targetSlot := []types.Slot{{
+		VenueID:   provider.ID(),
+		CourtID:   params.CourtID,
+		CourtName: pressedButtonText(callback),
+		Start:     start,
+		End:       end,
+		Price:     params.Price,
+		Params:    params,
+	}}
+	// 使用者已指定場地，不再套用場地偏好
+	bookedSlot, err := provider.BookCourt(targetSlot, types.CourtPreference{}, fmt.Sprint(callback.Message.Chat.ID))
+	if err != nil {
 		logger.Log.Error("預約失敗，原因：" + err.Error())
 		text := fmt.Sprintf("預約失敗：%v，請重新選擇", err)
 		h.bot.SendMessage(callback.Message.Chat.ID, text)
 		return err
 	}
 
+	if userObj, err := h.getOrCreateUser(callback.Message.Chat.ID); err != nil {
+		logger.Log.Error("get or create user", zap.Error(err))
+	} else if _, err := h.booking.Record(context.Background(), userObj.ID, *bookedSlot, booking.SourceManual); err != nil {
+		logger.Log.Error("record booking", zap.Error(err))
+	}
+
 	h.bot.SendMessage(callback.Message.Chat.ID, "成功預約場地，請前往以下網址完成付款：")
-	h.bot.SendMessage(callback.Message.Chat.ID, h.nantun_sport.GetPaymentURL())
+	h.bot.SendMessage(callback.Message.Chat.ID, provider.GetPaymentURL())
 
 	return nil
 }
@@ -330,14 +584,7 @@ func (h *MessageHandler) handleBooking(callback *tgbotapi.CallbackQuery) error {
 func (h *MessageHandler) handleStart(message *tgbotapi.Message) {
 	text := "歡迎使用運動中心查詢機器人！\n請選擇您要查詢的場地。"
 
-	keyboard := tgbotapi.NewInlineKeyboardMarkup(
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("南屯運動中心", "nantun_sport"),
-		),
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("朝馬運動中心", "chao_ma_sport"),
-		),
-	)
+	keyboard := h.createVenueKeyboard(message.Chat.ID)
 
 	// 發送帶有按鈕的消息
 	h.bot.SendeKeyboardMessage(message.Chat.ID, text, keyboard)
@@ -351,14 +598,24 @@ func (h *MessageHandler) handleDefault(message *tgbotapi.Message) {
 
 // 處理 /setting 命令
 func (h *MessageHandler) handleSetting(message *tgbotapi.Message) {
+	sess, err := h.session.Get(context.Background(), message.Chat.ID)
+	if err != nil {
+		logger.Log.Error("get session", zap.Error(err))
+		return
+	}
+	sess.State = stateWaitingAccount
+	if err := h.session.Save(context.Background(), sess); err != nil {
+		logger.Log.Error("save session", zap.Error(err))
+		return
+	}
+
 	text := "請輸入您的運動中心帳號："
 	h.bot.SendMessage(message.Chat.ID, text)
-	h.settingState[message.From.ID] = "waiting_account"
 }
 
 // 處理帳號輸入
-func (h *MessageHandler) handleAccountInput(message *tgbotapi.Message) {
-	userObj, err := h.getOrCreateUser(message.From.ID)
+func (h *MessageHandler) handleAccountInput(message *tgbotapi.Message, sess *session.Session) {
+	userObj, err := h.getOrCreateUser(message.Chat.ID)
 	if err != nil {
 		logger.Log.Error("get or create user", zap.Error(err))
 		return
@@ -375,13 +632,19 @@ func (h *MessageHandler) handleAccountInput(message *tgbotapi.Message) {
 		return
 	}
 
+	sess.State = stateWaitingPassword
+	if err := h.session.Save(context.Background(), sess); err != nil {
+		logger.Log.Error("save session", zap.Error(err))
+		return
+	}
+
 	text := "請輸入您的運動中心密碼："
 	h.bot.SendMessage(message.Chat.ID, text)
 }
 
 // 處理密碼輸入
-func (h *MessageHandler) handlePasswordInput(message *tgbotapi.Message) {
-	userObj, err := h.getOrCreateUser(message.From.ID)
+func (h *MessageHandler) handlePasswordInput(message *tgbotapi.Message, sess *session.Session) {
+	userObj, err := h.getOrCreateUser(message.Chat.ID)
 	if err != nil {
 		logger.Log.Error("get or create user", zap.Error(err))
 		return
@@ -398,10 +661,583 @@ func (h *MessageHandler) handlePasswordInput(message *tgbotapi.Message) {
 		return
 	}
 
+	// 清除設定狀態
+	sess.State = ""
+	if err := h.session.Save(context.Background(), sess); err != nil {
+		logger.Log.Error("save session", zap.Error(err))
+	}
+
 	text := "帳號密碼設定完成！"
 	h.bot.SendMessage(message.Chat.ID, text)
 }
 
+// 處理 /preference 命令
+func (h *MessageHandler) handlePreference(message *tgbotapi.Message) {
+	userObj, err := h.getOrCreateUser(message.Chat.ID)
+	if err != nil {
+		logger.Log.Error("get or create user", zap.Error(err))
+		return
+	}
+
+	sess, err := h.session.Get(context.Background(), message.Chat.ID)
+	if err != nil {
+		logger.Log.Error("get session", zap.Error(err))
+		return
+	}
+	sess.State = stateWaitingPreference
+	if err := h.session.Save(context.Background(), sess); err != nil {
+		logger.Log.Error("save session", zap.Error(err))
+		return
+	}
+
+	current := "未設定"
+	if userObj.CourtPreference != "" {
+		current = userObj.CourtPreference
+	}
+	text := "目前場地偏好：" + current +
+		"\n請輸入場地偏好，依序優先，! 開頭表示不預約，例如：羽球A場>羽球C場 !羽球F場" +
+		"\n輸入「清除」可移除偏好"
+	h.bot.SendMessage(message.Chat.ID, text)
+}
+
+// 處理場地偏好輸入
+func (h *MessageHandler) handlePreferenceInput(message *tgbotapi.Message, sess *session.Session) {
+	userObj, err := h.getOrCreateUser(message.Chat.ID)
+	if err != nil {
+		logger.Log.Error("get or create user", zap.Error(err))
+		return
+	}
+
+	preference := ""
+	if input := strings.TrimSpace(message.Text); input != "清除" {
+		pref := types.ParseCourtPreference(input)
+		if pref.IsEmpty() {
+			h.bot.SendMessage(message.Chat.ID, "格式錯誤，請重新輸入：")
+			return
+		}
+		preference = pref.String()
+	}
+
+	err = h.user.Update(context.Background(), userObj.ID, map[string]interface{}{
+		"court_preference": preference,
+	})
+	if err != nil {
+		logger.Log.Error("update user court preference", zap.Error(err))
+		h.bot.SendMessage(message.Chat.ID, "設定場地偏好失敗，請重試")
+		return
+	}
+
+	// 清除設定狀態
+	sess.State = ""
+	if err := h.session.Save(context.Background(), sess); err != nil {
+		logger.Log.Error("save session", zap.Error(err))
+	}
+
+	if preference == "" {
+		h.bot.SendMessage(message.Chat.ID, "已清除場地偏好")
+		return
+	}
+	h.bot.SendMessage(message.Chat.ID, "場地偏好設定完成："+preference)
+}
+
+// #endregion
+
+// #region 訂閱管理
+// 處理 /subscriptions 命令，列出使用者的訂閱
+func (h *MessageHandler) handleSubscriptions(chatID int64) {
+	userObj, err := h.getOrCreateUser(chatID)
+	if err != nil {
+		logger.Log.Error("get or create user", zap.Error(err))
+		return
+	}
+
+	schedules, err := h.schedule.GetByUserID(context.Background(), userObj.ID)
+	if err != nil {
+		logger.Log.Error("get schedules", zap.Error(err))
+		h.bot.SendMessage(chatID, "取得訂閱失敗，請稍後再試")
+		return
+	}
+
+	if len(schedules) == 0 {
+		h.bot.SendMessage(chatID, "目前沒有任何訂閱，請使用 /start 選擇場地與時段")
+		return
+	}
+
+	var lines []string
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i, subs := range schedules {
+		lines = append(lines, fmt.Sprintf("%d. %s", i+1, h.formatSchedule(subs)))
+
+		pauseText := fmt.Sprintf("暫停 %d", i+1)
+		if subs.Paused {
+			pauseText = fmt.Sprintf("恢復 %d", i+1)
+		}
+		autoBookText := fmt.Sprintf("自動 %d", i+1)
+		if subs.AutoBook {
+			autoBookText = fmt.Sprintf("手動 %d", i+1)
+		}
+		arg := fmt.Sprint(subs.ID)
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			h.newButton(chatID, fmt.Sprintf("刪除 %d", i+1), CallbackData{Action: actionSubDelete, Arg: arg}),
+			h.newButton(chatID, pauseText, CallbackData{Action: actionSubPause, Arg: arg}),
+			h.newButton(chatID, fmt.Sprintf("期限 %d", i+1), CallbackData{Action: actionSubExpiry, Arg: arg}),
+			h.newButton(chatID, autoBookText, CallbackData{Action: actionSubAutoBook, Arg: arg}),
+		))
+	}
+
+	text := "您的訂閱：\n" + strings.Join(lines, "\n") +
+		"\n\n自動預約條件可使用 /autobook <編號> <最高價格> <每週上限> [場地...] 設定"
+	if !userObj.Status {
+		text += "\n\n目前已暫停所有通知，使用 /resume 恢復"
+	}
+	h.bot.SendeKeyboardMessage(chatID, text, tgbotapi.NewInlineKeyboardMarkup(rows...))
+}
+
+// 詢問是否刪除訂閱
+func (h *MessageHandler) handleSubscriptionDelete(callback *tgbotapi.CallbackQuery, data CallbackData) {
+	subs, err := h.getOwnSchedule(callback.Message.Chat.ID, data.Arg)
+	if err != nil {
+		logger.Log.Error("get own schedule", zap.Error(err))
+		h.bot.SendMessage(callback.Message.Chat.ID, "找不到此訂閱")
+		return
+	}
+
+	text := "確定要刪除以下訂閱？\n" + h.formatSchedule(subs)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			h.newButton(callback.Message.Chat.ID, "確定刪除", CallbackData{Action: actionSubDeleteConfirm, Arg: data.Arg}),
+			h.newButton(callback.Message.Chat.ID, "取消", CallbackData{Action: actionSubList}),
+		),
+	)
+	h.bot.SendeKeyboardMessage(callback.Message.Chat.ID, text, keyboard)
+}
+
+// 刪除訂閱
+func (h *MessageHandler) handleSubscriptionDeleteConfirm(callback *tgbotapi.CallbackQuery, data CallbackData) {
+	subs, err := h.getOwnSchedule(callback.Message.Chat.ID, data.Arg)
+	if err != nil {
+		logger.Log.Error("get own schedule", zap.Error(err))
+		h.bot.SendMessage(callback.Message.Chat.ID, "找不到此訂閱")
+		return
+	}
+
+	if err := h.schedule.Delete(context.Background(), subs.ID); err != nil {
+		logger.Log.Error("delete schedule", zap.Error(err))
+		h.bot.SendMessage(callback.Message.Chat.ID, "刪除訂閱失敗，請稍後再試")
+		return
+	}
+
+	h.bot.SendMessage(callback.Message.Chat.ID, "已刪除訂閱："+h.formatSchedule(subs))
+}
+
+// 暫停或恢復單一訂閱
+func (h *MessageHandler) handleSubscriptionPause(callback *tgbotapi.CallbackQuery, data CallbackData) {
+	subs, err := h.getOwnSchedule(callback.Message.Chat.ID, data.Arg)
+	if err != nil {
+		logger.Log.Error("get own schedule", zap.Error(err))
+		h.bot.SendMessage(callback.Message.Chat.ID, "找不到此訂閱")
+		return
+	}
+
+	subs.Paused = !subs.Paused
+	if err := h.schedule.UpdateColumns(context.Background(), subs.ID, map[string]interface{}{"paused": subs.Paused}); err != nil {
+		logger.Log.Error("update schedule", zap.Error(err))
+		h.bot.SendMessage(callback.Message.Chat.ID, "更新訂閱失敗，請稍後再試")
+		return
+	}
+
+	h.handleSubscriptions(callback.Message.Chat.ID)
+}
+
+// 開關單一訂閱的自動預約
+func (h *MessageHandler) handleSubscriptionAutoBook(callback *tgbotapi.CallbackQuery, data CallbackData) {
+	subs, err := h.getOwnSchedule(callback.Message.Chat.ID, data.Arg)
+	if err != nil {
+		logger.Log.Error("get own schedule", zap.Error(err))
+		h.bot.SendMessage(callback.Message.Chat.ID, "找不到此訂閱")
+		return
+	}
+
+	subs.AutoBook = !subs.AutoBook
+	if err := h.schedule.UpdateColumns(context.Background(), subs.ID, map[string]interface{}{"auto_book": subs.AutoBook}); err != nil {
+		logger.Log.Error("update schedule", zap.Error(err))
+		h.bot.SendMessage(callback.Message.Chat.ID, "更新訂閱失敗，請稍後再試")
+		return
+	}
+
+	h.handleSubscriptions(callback.Message.Chat.ID)
+}
+
+// 處理 /autobook 命令，例如：/autobook 1 300 2 羽球A場 羽球C場
+func (h *MessageHandler) handleAutoBookCommand(message *tgbotapi.Message, args []string) {
+	usage := "用法：/autobook <編號> <最高價格> <每週上限> [場地...]\n" +
+		"編號為 /subscriptions 列表中的編號，價格與上限填 0 表示不限，場地依序優先，! 開頭表示不預約\n" +
+		"未指定場地時使用 /preference 的設定"
+	if len(args) < 3 {
+		h.bot.SendMessage(message.Chat.ID, usage)
+		return
+	}
+
+	index, err1 := strconv.Atoi(args[0])
+	maxPrice, err2 := strconv.Atoi(args[1])
+	maxBookings, err3 := strconv.Atoi(args[2])
+	if err1 != nil || err2 != nil || err3 != nil || index < 1 || maxPrice < 0 || maxBookings < 0 {
+		h.bot.SendMessage(message.Chat.ID, usage)
+		return
+	}
+
+	userObj, err := h.getOrCreateUser(message.Chat.ID)
+	if err != nil {
+		logger.Log.Error("get or create user", zap.Error(err))
+		return
+	}
+
+	schedules, err := h.schedule.GetByUserID(context.Background(), userObj.ID)
+	if err != nil {
+		logger.Log.Error("get schedules", zap.Error(err))
+		h.bot.SendMessage(message.Chat.ID, "取得訂閱失敗，請稍後再試")
+		return
+	}
+	if index > len(schedules) {
+		h.bot.SendMessage(message.Chat.ID, "找不到此訂閱，請使用 /subscriptions 查看編號")
+		return
+	}
+
+	subs := schedules[index-1]
+	subs.AutoBook = true
+	subs.MaxPrice = maxPrice
+	subs.MaxBookingsPerWeek = maxBookings
+	subs.CourtPreference = types.ParseCourtPreference(strings.Join(args[3:], " ")).String()
+	if err := h.schedule.UpdateColumns(context.Background(), subs.ID, map[string]interface{}{
+		"auto_book":             subs.AutoBook,
+		"max_price":             subs.MaxPrice,
+		"max_bookings_per_week": subs.MaxBookingsPerWeek,
+		"court_preference":      subs.CourtPreference,
+	}); err != nil {
+		logger.Log.Error("update schedule", zap.Error(err))
+		h.bot.SendMessage(message.Chat.ID, "更新訂閱失敗，請稍後再試")
+		return
+	}
+
+	h.bot.SendMessage(message.Chat.ID, "已開啟自動預約："+h.formatSchedule(subs))
+}
+
+// 請使用者輸入訂閱期限
+func (h *MessageHandler) handleSubscriptionExpiry(callback *tgbotapi.CallbackQuery, data CallbackData) {
+	subs, err := h.getOwnSchedule(callback.Message.Chat.ID, data.Arg)
+	if err != nil {
+		logger.Log.Error("get own schedule", zap.Error(err))
+		h.bot.SendMessage(callback.Message.Chat.ID, "找不到此訂閱")
+		return
+	}
+
+	sess, err := h.session.Get(context.Background(), callback.Message.Chat.ID)
+	if err != nil {
+		logger.Log.Error("get session", zap.Error(err))
+		return
+	}
+	sess.State = stateWaitingExpiry
+	sess.ScheduleID = subs.ID
+	if err := h.session.Save(context.Background(), sess); err != nil {
+		logger.Log.Error("save session", zap.Error(err))
+		return
+	}
+
+	text := "設定訂閱期限：" + h.formatSchedule(subs) +
+		"\n請輸入到期日（例如 2026-12-31），或起訖日期（例如 2026-11-01~2026-12-31）" +
+		"\n輸入「清除」可移除期限"
+	h.bot.SendMessage(callback.Message.Chat.ID, text)
+}
+
+// 處理訂閱期限輸入
+func (h *MessageHandler) handleExpiryInput(message *tgbotapi.Message, sess *session.Session) {
+	subs, err := h.getOwnSchedule(message.Chat.ID, fmt.Sprint(sess.ScheduleID))
+	if err != nil {
+		logger.Log.Error("get own schedule", zap.Error(err))
+		h.session.Clear(context.Background(), message.Chat.ID)
+		h.bot.SendMessage(message.Chat.ID, "找不到此訂閱")
+		return
+	}
+
+	input := strings.TrimSpace(message.Text)
+	if input == "清除" {
+		subs.StartDate = nil
+		subs.EndDate = nil
+	} else {
+		startText, endText, isRange := strings.Cut(input, "~")
+		if !isRange {
+			startText, endText = "", input
+		}
+
+		endDate, err := types.ParseDate(endText)
+		if err != nil {
+			h.bot.SendMessage(message.Chat.ID, "日期格式錯誤，請重新輸入：")
+			return
+		}
+		subs.StartDate = nil
+		subs.EndDate = &endDate
+
+		if isRange {
+			startDate, err := types.ParseDate(startText)
+			if err != nil || startDate.After(endDate) {
+				h.bot.SendMessage(message.Chat.ID, "起訖日期錯誤，請重新輸入：")
+				return
+			}
+			subs.StartDate = &startDate
+		}
+
+		if subs.Expired(h.clock.Now()) {
+			h.bot.SendMessage(message.Chat.ID, "期限內沒有符合的日期，請重新輸入：")
+			return
+		}
+	}
+
+	if err := h.schedule.UpdateColumns(context.Background(), subs.ID, map[string]interface{}{
+		"start_date": subs.StartDate,
+		"end_date":   subs.EndDate,
+	}); err != nil {
+		logger.Log.Error("update schedule", zap.Error(err))
+		h.bot.SendMessage(message.Chat.ID, "更新訂閱失敗，請稍後再試")
+		return
+	}
+
+	if err := h.session.Clear(context.Background(), message.Chat.ID); err != nil {
+		logger.Log.Error("clear session", zap.Error(err))
+	}
+	h.handleSubscriptions(message.Chat.ID)
+}
+
+// 處理 /pause、/resume 命令，切換使用者所有通知
+func (h *MessageHandler) handleUserStatus(message *tgbotapi.Message, status bool) {
+	userObj, err := h.getOrCreateUser(message.Chat.ID)
+	if err != nil {
+		logger.Log.Error("get or create user", zap.Error(err))
+		return
+	}
+
+	err = h.user.Update(context.Background(), userObj.ID, map[string]interface{}{
+		"status": status,
+	})
+	if err != nil {
+		logger.Log.Error("update user status", zap.Error(err))
+		h.bot.SendMessage(message.Chat.ID, "設定失敗，請重試")
+		return
+	}
+
+	if status {
+		h.bot.SendMessage(message.Chat.ID, "已恢復訂閱通知")
+	} else {
+		h.bot.SendMessage(message.Chat.ID, "已暫停所有訂閱通知，使用 /resume 恢復")
+	}
+}
+
+// 取得屬於該使用者的訂閱
+func (h *MessageHandler) getOwnSchedule(chatID int64, arg string) (*schedule.Schedule, error) {
+	scheduleID, err := strconv.ParseUint(arg, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule id: %w", err)
+	}
+
+	userObj, err := h.getOrCreateUser(chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	subs, err := h.schedule.GetByID(context.Background(), uint(scheduleID))
+	if err != nil {
+		return nil, err
+	}
+	if subs.UserID != userObj.ID {
+		return nil, fmt.Errorf("schedule %d not owned by user %d", subs.ID, userObj.ID)
+	}
+	return subs, nil
+}
+
+// 訂閱顯示文字，例如：南屯運動中心 星期二 19:00-20:00（至 2026-12-31）
+func (h *MessageHandler) formatSchedule(subs *schedule.Schedule) string {
+	venueName := subs.VenueID
+	if provider, err := h.providers.Get(types.VenueID(subs.VenueID)); err == nil {
+		venueName = provider.Name()
+	}
+
+	text := fmt.Sprintf("%s 星期%s", venueName, types.WeekdayName(subs.Weekday))
+	if subs.Date != nil {
+		text = fmt.Sprintf("%s %s（星期%s）", venueName, subs.Date.Format(types.DateLayout), types.WeekdayName(subs.Weekday))
+	}
+	if subs.TimeSlot != nil {
+		text += fmt.Sprintf(" %s-%s", subs.TimeSlot.StartTime.Format("15:04"), subs.TimeSlot.EndTime.Format("15:04"))
+	}
+	switch {
+	case subs.StartDate != nil && subs.EndDate != nil:
+		text += fmt.Sprintf("（%s 至 %s）", subs.StartDate.Format(types.DateLayout), subs.EndDate.Format(types.DateLayout))
+	case subs.EndDate != nil:
+		text += fmt.Sprintf("（至 %s）", subs.EndDate.Format(types.DateLayout))
+	}
+	if subs.AutoBook {
+		text += "（自動預約"
+		if subs.MaxPrice > 0 {
+			text += fmt.Sprintf("，%d 元內", subs.MaxPrice)
+		}
+		if subs.MaxBookingsPerWeek > 0 {
+			text += fmt.Sprintf("，每週 %d 次", subs.MaxBookingsPerWeek)
+		}
+		if pref := subs.Preference(); !pref.IsEmpty() {
+			text += "，" + pref.String()
+		}
+		text += "）"
+	}
+	if subs.Paused {
+		text += "（已暫停）"
+	}
+	return text
+}
+
+// #endregion
+
+// #region 預約紀錄
+// 處理 /mybookings 命令，列出最近的預約
+func (h *MessageHandler) handleMyBookings(chatID int64) {
+	userObj, err := h.getOrCreateUser(chatID)
+	if err != nil {
+		logger.Log.Error("get or create user", zap.Error(err))
+		return
+	}
+
+	bookings, err := h.booking.GetByUserID(context.Background(), userObj.ID, 10)
+	if err != nil {
+		logger.Log.Error("get bookings", zap.Error(err))
+		h.bot.SendMessage(chatID, "取得預約紀錄失敗，請稍後再試")
+		return
+	}
+
+	if len(bookings) == 0 {
+		h.bot.SendMessage(chatID, "目前沒有任何預約紀錄")
+		return
+	}
+
+	today := types.DateOnly(h.clock.Now())
+	var lines []string
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i, b := range bookings {
+		lines = append(lines, fmt.Sprintf("%d. %s", i+1, h.formatBooking(b)))
+
+		// 尚未使用的有效預約才能取消
+		if b.Active() && !b.Date.Before(today) {
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+				h.newButton(chatID, fmt.Sprintf("取消 %d", i+1), CallbackData{Action: actionBookingCancel, Arg: fmt.Sprint(b.ID)}),
+			))
+		}
+	}
+
+	text := "您最近的預約：\n" + strings.Join(lines, "\n")
+	if len(rows) == 0 {
+		h.bot.SendMessage(chatID, text)
+		return
+	}
+	h.bot.SendeKeyboardMessage(chatID, text, tgbotapi.NewInlineKeyboardMarkup(rows...))
+}
+
+// 詢問是否取消預約
+func (h *MessageHandler) handleBookingCancel(callback *tgbotapi.CallbackQuery, data CallbackData) {
+	b, err := h.getOwnBooking(callback.Message.Chat.ID, data.Arg)
+	if err != nil {
+		logger.Log.Error("get own booking", zap.Error(err))
+		h.bot.SendMessage(callback.Message.Chat.ID, "找不到此預約")
+		return
+	}
+
+	text := "確定要取消以下預約？\n" + h.formatBooking(b)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			h.newButton(callback.Message.Chat.ID, "確定取消", CallbackData{Action: actionBookingCancelConfirm, Arg: data.Arg}),
+			h.newButton(callback.Message.Chat.ID, "返回", CallbackData{Action: actionBackToMain}),
+		),
+	)
+	h.bot.SendeKeyboardMessage(callback.Message.Chat.ID, text, keyboard)
+}
+
+// 在運動中心網站取消預約
+func (h *MessageHandler) handleBookingCancelConfirm(callback *tgbotapi.CallbackQuery, data CallbackData) {
+	b, err := h.getOwnBooking(callback.Message.Chat.ID, data.Arg)
+	if err != nil {
+		logger.Log.Error("get own booking", zap.Error(err))
+		h.bot.SendMessage(callback.Message.Chat.ID, "找不到此預約")
+		return
+	}
+	if !b.Active() {
+		h.bot.SendMessage(callback.Message.Chat.ID, fmt.Sprintf("此預約目前為%s，無法取消", b.Status.Label()))
+		return
+	}
+
+	provider, err := h.providers.Get(types.VenueID(b.VenueID))
+	if err != nil {
+		logger.Log.Error("get provider", zap.Error(err))
+		return
+	}
+
+	if err := provider.CancelBooking(b.Order(), fmt.Sprint(callback.Message.Chat.ID)); err != nil {
+		logger.Log.Error("cancel booking", zap.Uint("bookingID", b.ID), zap.Error(err))
+		h.bot.SendMessage(callback.Message.Chat.ID, fmt.Sprintf("取消失敗：%v", err))
+		return
+	}
+
+	if err := h.booking.UpdateStatus(context.Background(), b.ID, booking.StatusCancelled); err != nil {
+		logger.Log.Error("update booking status", zap.Uint("bookingID", b.ID), zap.Error(err))
+	}
+	h.bot.SendMessage(callback.Message.Chat.ID, "已取消預約："+h.formatBooking(b))
+
+	// 場地已釋出，通知排程重新檢查訂閱
+	if h.released != nil {
+		h.released.CourtReleased(provider.ID(), b.Date)
+	}
+}
+
+// 取得使用者自己的預約紀錄
+func (h *MessageHandler) getOwnBooking(chatID int64, arg string) (*booking.Booking, error) {
+	bookingID, err := strconv.ParseUint(arg, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid booking id: %w", err)
+	}
+
+	userObj, err := h.getOrCreateUser(chatID)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := h.booking.GetByID(context.Background(), uint(bookingID))
+	if err != nil {
+		return nil, err
+	}
+	if b.UserID != userObj.ID {
+		return nil, fmt.Errorf("booking %d not owned by user %d", b.ID, userObj.ID)
+	}
+	return b, nil
+}
+
+// 預約顯示文字，例如：南屯運動中心 2026-11-03 19:00-20:00 羽球A場 250 元【待付款】（自動）
+func (h *MessageHandler) formatBooking(b *booking.Booking) string {
+	venueName := b.VenueID
+	if provider, err := h.providers.Get(types.VenueID(b.VenueID)); err == nil {
+		venueName = provider.Name()
+	}
+
+	text := fmt.Sprintf("%s %s", venueName, b.Date.Format(types.DateLayout))
+	if !b.StartTime.IsZero() {
+		text += fmt.Sprintf(" %s-%s", b.StartTime.Format("15:04"), b.EndTime.Format("15:04"))
+	}
+	if b.CourtName != "" {
+		text += " " + b.CourtName
+	}
+	if b.Price > 0 {
+		text += fmt.Sprintf(" %d 元", b.Price)
+	}
+	text += fmt.Sprintf("【%s】（%s）", b.Status.Label(), b.Source.Label())
+	if b.Status == booking.StatusPendingPayment && b.PaymentDeadline != nil {
+		text += fmt.Sprintf("\n   繳費期限 %s", b.PaymentDeadline.Format("2006-01-02 15:04"))
+	}
+	return text
+}
+
 // #endregion
 
 // #region 南屯場地
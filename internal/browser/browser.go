@@ -1,7 +1,12 @@
 package browser
 
 import (
+	"container/list"
+	"context"
+	"errors"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/go-rod/rod"
 	"github.com/go-rod/rod/lib/launcher"
@@ -9,115 +14,445 @@ import (
 	"github.com/go-rod/stealth"
 
 	"github.com/tian841224/crawler_sportcenter/pkg/logger"
+	"go.uber.org/zap"
 )
 
+// 等待分頁歸還的最長時間
+const acquireTimeout = 2 * time.Minute
+
+// ErrClosed 瀏覽器已關閉
+var ErrClosed = errors.New("瀏覽器已關閉")
+
 type BrowserInterface interface {
-	initBrowser() error
-	initPage(tag string) (*rod.Page, error)
-	setWebMode(isMobileMode bool) error
-	GetPage(url string, tag string) (*rod.Page, error)
-	SwitchToPageByTag(tag string) (*rod.Page, error)
+	Acquire(ctx context.Context, url string, tag string) (*Lease, error)
+	TryAcquire(tag string) (*Lease, bool)
 	Close() error
 }
 
 var _ BrowserInterface = (*BrowserService)(nil)
 
+// Options 瀏覽器啟動與分頁池設定
+type Options struct {
+	MaxPages    int           // 同時開啟的分頁上限
+	IdleTimeout time.Duration // 閒置超過此時間的分頁會被關閉，0 表示不關閉
+	Headless    bool          // 無頭模式，沒有圖形介面的伺服器需開啟
+	BinPath     string        // 瀏覽器執行檔路徑，未設定時自動尋找或下載
+	UserDataDir string        // 使用者資料目錄，未設定時使用暫存目錄
+	Proxy       string        // 代理伺服器，例如 127.0.0.1:8080
+	ControlURL  string        // 連線至已啟動瀏覽器的 DevTools 網址，例如 ws://chrome:9222 或 http://chrome:9222
+	ManagerURL  string        // rod manager 網址，由 manager 啟動瀏覽器，例如 ws://rod:7317
+}
+
+// BrowserService 共用一個瀏覽器，每個標籤使用獨立的無痕環境與分頁
+// 標籤由呼叫端組成，需同時區分場館與使用者，避免不同網站共用分頁
+// 分頁以借用／歸還的方式使用，同一標籤同時只會借給一個呼叫端
+// 分頁數量達上限時關閉最久未使用的閒置分頁
 type BrowserService struct {
-	browser *rod.Browser
-	page    *rod.Page
-	pages   []PageInfo
+	options     Options
+	launchMutex sync.Mutex // 保護 browser，啟動瀏覽器時不阻擋歸還分頁
+	browser     *rod.Browser
+	mutex       sync.Mutex
+	pages       map[string]*pageEntry
+	lru         *list.List    // 最近使用的在前
+	changed     chan struct{} // 分頁歸還或關閉時通知等待中的呼叫端
+	closed      bool
+	done        chan struct{}
 }
 
-type PageInfo struct {
-	Page  *rod.Page
-	Tag   string
-	pages []PageInfo
+type pageEntry struct {
+	tag       string
+	page      *rod.Page
+	incognito *rod.Browser // 該標籤專屬的無痕瀏覽環境
+	leased    bool
+	lastUsed  time.Time
+	element   *list.Element
 }
 
-func NewBrowserService() BrowserService {
-	return BrowserService{
-		pages: make([]PageInfo, 0),
+// Lease 借出的分頁，使用完畢需呼叫 Release 歸還
+type Lease struct {
+	Page *rod.Page
+	Tag  string
+	New  bool // 分頁剛建立，尚未登入
+
+	service *BrowserService
+	entry   *pageEntry
+	once    sync.Once
+}
+
+func NewBrowserService(options Options) *BrowserService {
+	if options.MaxPages <= 0 {
+		options.MaxPages = 1
+	}
+
+	s := &BrowserService{
+		options: options,
+		pages:   make(map[string]*pageEntry),
+		lru:     list.New(),
+		changed: make(chan struct{}),
+		done:    make(chan struct{}),
 	}
+	if options.IdleTimeout > 0 {
+		go s.closeIdleLoop()
+	}
+	return s
 }
 
-// 取得頁面
-func (s *BrowserService) GetPage(url string, tag string) (*rod.Page, error) {
-	if s.browser == nil {
-		if err := s.initBrowser(); err != nil {
-			return nil, err
+// Acquire 借用標籤的分頁，分頁不存在時建立並前往網址
+// 該標籤的分頁借用中，或分頁數量已達上限且沒有閒置分頁時，等待其他呼叫端歸還
+func (s *BrowserService) Acquire(ctx context.Context, url string, tag string) (*Lease, error) {
+	ctx, cancel := context.WithTimeout(ctx, acquireTimeout)
+	defer cancel()
+
+	for {
+		s.mutex.Lock()
+		if s.closed {
+			s.mutex.Unlock()
+			return nil, ErrClosed
+		}
+
+		if entry, exists := s.pages[tag]; exists {
+			if !entry.leased {
+				lease := s.lease(entry)
+				s.mutex.Unlock()
+				return lease, nil
+			}
+		} else if evicted, ok := s.reserve(); ok {
+			// 先佔住位置再建立分頁，建立期間不持有鎖
+			entry := &pageEntry{tag: tag, leased: true}
+			entry.element = s.lru.PushFront(entry)
+			s.pages[tag] = entry
+			s.mutex.Unlock()
+
+			closeEntries(evicted)
+			return s.open(entry, url)
+		}
+
+		wait := s.changed
+		s.mutex.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("等待標籤 %s 的分頁逾時: %w", tag, ctx.Err())
+		case <-wait:
 		}
 	}
+}
+
+// TryAcquire 借用標籤已開啟且閒置的分頁，不建立新分頁也不等待
+func (s *BrowserService) TryAcquire(tag string) (*Lease, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
 
-	if s.hasTag(tag) {
-		return s.SwitchToPageByTag(tag)
+	entry, exists := s.pages[tag]
+	if s.closed || !exists || entry.leased {
+		return nil, false
 	}
+	return s.lease(entry), true
+}
+
+// 借出既有分頁，需持有鎖
+func (s *BrowserService) lease(entry *pageEntry) *Lease {
+	entry.leased = true
+	s.lru.MoveToFront(entry.element)
+	return &Lease{Page: entry.page, Tag: entry.tag, service: s, entry: entry}
+}
+
+// Release 歸還分頁，保留登入狀態供下次使用
+func (l *Lease) Release() {
+	l.once.Do(func() {
+		l.service.release(l.entry)
+	})
+}
 
-	page, err := s.initPage(tag)
+// Discard 關閉分頁，下次借用時重新建立，用於登入失敗等頁面狀態不明的情況
+func (l *Lease) Discard() {
+	l.once.Do(func() {
+		l.service.discard(l.entry)
+	})
+}
+
+// Close 關閉所有分頁與瀏覽器
+func (s *BrowserService) Close() error {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.done)
+
+	entries := make([]*pageEntry, 0, len(s.pages))
+	for _, entry := range s.pages {
+		entries = append(entries, entry)
+	}
+	s.pages = make(map[string]*pageEntry)
+	s.lru.Init()
+	s.notify()
+	s.mutex.Unlock()
+
+	closeEntries(entries)
+
+	s.launchMutex.Lock()
+	defer s.launchMutex.Unlock()
+	// 既有瀏覽器由其他程式管理，只關閉自己開的分頁
+	if s.browser != nil && s.options.ControlURL == "" {
+		return s.browser.Close()
+	}
+	return nil
+}
+
+// 建立分頁並前往網址，失敗時釋出佔用的位置
+func (s *BrowserService) open(entry *pageEntry, url string) (*Lease, error) {
+	page, incognito, err := s.initPage()
 	if err != nil {
+		s.discard(entry)
 		return nil, err
 	}
-	s.setWebMode(true)
 
-	if err = page.Navigate(url); err != nil {
+	// 建立期間可能已關閉瀏覽器，分頁欄位需在持有鎖時寫入
+	s.mutex.Lock()
+	closed := s.closed
+	entry.page = page
+	entry.incognito = incognito
+	s.mutex.Unlock()
+	if closed {
+		s.discard(entry)
+		return nil, ErrClosed
+	}
+
+	if err := page.Navigate(url); err != nil {
+		s.discard(entry)
 		return nil, err
 	}
 
-	return page, nil
+	logger.Log.Info("開啟分頁", zap.String("tag", entry.tag))
+	return &Lease{Page: page, Tag: entry.tag, New: true, service: s, entry: entry}, nil
 }
 
-// 根據標籤切換頁面
-func (s *BrowserService) SwitchToPageByTag(tag string) (*rod.Page, error) {
-	for _, p := range s.pages {
-		if p.Tag == tag {
-			s.page = p.Page
-			s.page.MustActivate()
-			return p.Page, nil
+// 確保有空位可以開新分頁，必要時移除最久未使用的閒置分頁，需持有鎖
+func (s *BrowserService) reserve() ([]*pageEntry, bool) {
+	if len(s.pages) < s.options.MaxPages {
+		return nil, true
+	}
+
+	for element := s.lru.Back(); element != nil; element = element.Prev() {
+		entry := element.Value.(*pageEntry)
+		if entry.leased {
+			continue
 		}
+		s.remove(entry)
+		logger.Log.Info("分頁數量已達上限，關閉最久未使用的分頁", zap.String("tag", entry.tag))
+		return []*pageEntry{entry}, true
 	}
-	return nil, fmt.Errorf("找不到標籤為 %s 的頁面", tag)
+	return nil, false
 }
 
-// 關閉瀏覽器
-func (s *BrowserService) Close() error {
-	for _, pageInfo := range s.pages {
-		if pageInfo.Page != nil {
-			pageInfo.Page.Close()
+func (s *BrowserService) release(entry *pageEntry) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	entry.leased = false
+	entry.lastUsed = time.Now()
+	s.notify()
+}
+
+func (s *BrowserService) discard(entry *pageEntry) {
+	s.mutex.Lock()
+	if s.pages[entry.tag] == entry {
+		s.remove(entry)
+	}
+	s.notify()
+	s.mutex.Unlock()
+
+	closeEntries([]*pageEntry{entry})
+}
+
+// 從池中移除，需持有鎖
+func (s *BrowserService) remove(entry *pageEntry) {
+	delete(s.pages, entry.tag)
+	s.lru.Remove(entry.element)
+}
+
+func (s *BrowserService) isClosed() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.closed
+}
+
+// 通知等待中的呼叫端，需持有鎖
+func (s *BrowserService) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// 定期關閉閒置過久的分頁
+func (s *BrowserService) closeIdleLoop() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.closeIdle(time.Now())
+		case <-s.done:
+			return
 		}
 	}
+}
 
-	if s.browser != nil {
-		return s.browser.Close()
+func (s *BrowserService) closeIdle(now time.Time) {
+	s.mutex.Lock()
+	var idle []*pageEntry
+	for element := s.lru.Back(); element != nil; {
+		entry := element.Value.(*pageEntry)
+		element = element.Prev()
+		if entry.leased || now.Sub(entry.lastUsed) < s.options.IdleTimeout {
+			continue
+		}
+		s.remove(entry)
+		idle = append(idle, entry)
 	}
-	return nil
+	if len(idle) > 0 {
+		s.notify()
+	}
+	s.mutex.Unlock()
+
+	for _, entry := range idle {
+		logger.Log.Info("關閉閒置分頁", zap.String("tag", entry.tag))
+	}
+	closeEntries(idle)
 }
 
-// 初始化爬蟲
+func closeEntries(entries []*pageEntry) {
+	for _, entry := range entries {
+		if entry.page != nil {
+			entry.page.Close()
+		}
+		if entry.incognito != nil {
+			entry.incognito.Close()
+		}
+	}
+}
+
+// 取得瀏覽器，第一次使用時啟動
+func (s *BrowserService) getBrowser() (*rod.Browser, error) {
+	s.launchMutex.Lock()
+	defer s.launchMutex.Unlock()
+
+	// 關閉後不再啟動新的瀏覽器
+	if s.isClosed() {
+		return nil, ErrClosed
+	}
+
+	if s.browser == nil {
+		if err := s.initBrowser(); err != nil {
+			return nil, err
+		}
+	}
+	return s.browser, nil
+}
+
+// 初始化爬蟲，依設定連線至既有瀏覽器、rod manager 或在本機啟動
 func (s *BrowserService) initBrowser() error {
-	// 設定瀏覽器啟動選項
-	l := launcher.New().
-		Headless(false).
+	browser := rod.New()
+
+	switch {
+	case s.options.ControlURL != "":
+		controlURL, err := launcher.ResolveURL(s.options.ControlURL)
+		if err != nil {
+			logger.Log.Error("解析 DevTools 網址失敗:" + err.Error())
+			return err
+		}
+		logger.Log.Info("連線至既有瀏覽器", zap.String("url", controlURL))
+		browser = browser.ControlURL(controlURL)
+
+	case s.options.ManagerURL != "":
+		l, err := launcher.NewManaged(s.options.ManagerURL)
+		if err != nil {
+			logger.Log.Error("連線 rod manager 失敗:" + err.Error())
+			return err
+		}
+		client, err := s.configure(l).Client()
+		if err != nil {
+			logger.Log.Error("連線 rod manager 失敗:" + err.Error())
+			return err
+		}
+		logger.Log.Info("由 rod manager 啟動瀏覽器", zap.String("url", s.options.ManagerURL))
+		browser = browser.Client(client)
+
+	default:
+		path, err := s.configure(launcher.New()).Launch()
+		if err != nil {
+			logger.Log.Error("啟動瀏覽器失敗:" + err.Error())
+			return err
+		}
+		logger.Log.Info("啟動本機瀏覽器", zap.Bool("headless", s.options.Headless))
+		browser = browser.ControlURL(path)
+	}
+
+	// 初始化瀏覽器
+	if err := browser.Connect(); err != nil {
+		logger.Log.Error("連線瀏覽器失敗:" + err.Error())
+		return err
+	}
+
+	s.browser = browser
+
+	return nil
+}
+
+// 設定瀏覽器啟動選項
+func (s *BrowserService) configure(l *launcher.Launcher) *launcher.Launcher {
+	l = l.Headless(s.options.Headless).
 		Leakless(false). // Disable leakless mode
 		Set("disable-blink-features", "AutomationControlled").
 		Set("disable-features", "IsolateOrigins,site-per-process").
 		Devtools(false).
 		NoSandbox(true)
 
-	path := l.MustLaunch()
+	if s.options.BinPath != "" {
+		l = l.Bin(s.options.BinPath)
+	}
+	if s.options.UserDataDir != "" {
+		l = l.UserDataDir(s.options.UserDataDir)
+	}
+	if s.options.Proxy != "" {
+		l = l.Proxy(s.options.Proxy)
+	}
+	return l
+}
 
-	// 初始化瀏覽器
-	browser := rod.New().ControlURL(path).MustConnect()
+// 建立頁面
+func (s *BrowserService) initPage() (*rod.Page, *rod.Browser, error) {
+	browser, err := s.getBrowser()
+	if err != nil {
+		return nil, nil, err
+	}
 
-	s.browser = browser
+	// 每個標籤使用獨立的無痕瀏覽環境，避免不同使用者共用登入 Cookie
+	incognito, err := browser.Incognito()
+	if err != nil {
+		logger.Log.Error("建立無痕瀏覽環境失敗:" + err.Error())
+		return nil, nil, err
+	}
 
-	return nil
+	page, err := s.setupPage(incognito)
+	if err != nil {
+		incognito.Close()
+		return nil, nil, err
+	}
+	return page, incognito, nil
 }
 
-// 取得頁面
-func (s *BrowserService) initPage(tag string) (*rod.Page, error) {
+func (s *BrowserService) setupPage(incognito *rod.Browser) (*rod.Page, error) {
 	// 建立新頁面
-	s.page = stealth.MustPage(s.browser)
+	page, err := stealth.Page(incognito)
+	if err != nil {
+		logger.Log.Error("建立頁面失敗:" + err.Error())
+		return nil, err
+	}
 
-	err := s.page.SetUserAgent(&proto.NetworkSetUserAgentOverride{
+	err = page.SetUserAgent(&proto.NetworkSetUserAgentOverride{
 		UserAgent:      "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/136.0.0.0 Safari/537.36",
 		AcceptLanguage: "zh-TW,zh;q=0.9,en-US;q=0.8,en;q=0.7",
 	})
@@ -128,7 +463,7 @@ func (s *BrowserService) initPage(tag string) (*rod.Page, error) {
 	}
 
 	// 注入反檢測腳本
-	_, err = s.page.Eval(`() => {
+	_, err = page.Eval(`() => {
 		Object.defineProperty(navigator, 'webdriver', { get: () => false });
 		Object.defineProperty(navigator, 'plugins', { get: () => [1, 2, 3, 4, 5] });
 		Object.defineProperty(navigator, 'languages', { get: () => ['zh-TW', 'zh', 'en-US', 'en'] });
@@ -140,7 +475,7 @@ func (s *BrowserService) initPage(tag string) (*rod.Page, error) {
 	}
 
 	// 注入反彈窗腳本
-	_, err = s.page.Eval(`() => {
+	_, err = page.Eval(`() => {
 			window.alert = () => {};
 			window.confirm = () => true;
 			window.prompt = () => null;
@@ -151,19 +486,14 @@ func (s *BrowserService) initPage(tag string) (*rod.Page, error) {
 		return nil, err
 	}
 
-	s.pages = append(s.pages, PageInfo{
-		Page: s.page,
-		Tag:  tag,
-	})
-	return s.page, nil
+	if err = setWebMode(page, true); err != nil {
+		logger.Log.Warn("設定網頁模式失敗:" + err.Error())
+	}
+	return page, nil
 }
 
 // 設定網頁模式
-func (s *BrowserService) setWebMode(isMobileMode bool) error {
-	if s.page == nil {
-		return nil
-	}
-
+func setWebMode(page *rod.Page, isMobileMode bool) error {
 	// 使用正確的 proto.NetworkSetUserAgentOverride 結構
 	ua := &proto.NetworkSetUserAgentOverride{
 		UserAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36",
@@ -172,7 +502,7 @@ func (s *BrowserService) setWebMode(isMobileMode bool) error {
 	if isMobileMode {
 		ua.UserAgent = "Mozilla/5.0 (iPhone; CPU iPhone OS 14_7_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/14.1.2 Mobile/15E148 Safari/604.1"
 		// 設置移動設備參數
-		err := s.page.SetViewport(&proto.EmulationSetDeviceMetricsOverride{
+		err := page.SetViewport(&proto.EmulationSetDeviceMetricsOverride{
 			Width:             375, // iPhone 的寬度
 			Height:            812, // iPhone 的高度
 			DeviceScaleFactor: 3,   // 設備像素比
@@ -183,15 +513,5 @@ func (s *BrowserService) setWebMode(isMobileMode bool) error {
 		}
 	}
 
-	return s.page.SetUserAgent(ua)
-}
-
-// 新增：檢查標籤是否存在
-func (s *BrowserService) hasTag(tag string) bool {
-	for _, pageInfo := range s.pages {
-		if pageInfo.Tag == tag {
-			return true
-		}
-	}
-	return false
+	return page.SetUserAgent(ua)
 }
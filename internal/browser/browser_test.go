@@ -0,0 +1,223 @@
+package browser
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-rod/rod/lib/launcher"
+	"github.com/tian841224/crawler_sportcenter/pkg/logger"
+	"go.uber.org/zap"
+)
+
+func TestMain(m *testing.M) {
+	logger.Log = zap.NewNop()
+	os.Exit(m.Run())
+}
+
+// 需要本機已安裝的瀏覽器，找不到時略過，不自動下載
+func newTestService(t *testing.T, options Options) *BrowserService {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("starts a browser")
+	}
+	path, found := launcher.LookPath()
+	if !found {
+		t.Skip("找不到瀏覽器")
+	}
+	options.Headless = true
+	options.BinPath = path
+
+	s := NewBrowserService(options)
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html><head><title>test</title></head><body></body></html>"))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (s *BrowserService) hasPage(tag string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	_, exists := s.pages[tag]
+	return exists
+}
+
+func TestAcquireLeasesOneTagToOneCaller(t *testing.T) {
+	s := newTestService(t, Options{MaxPages: 2})
+	server := newTestServer(t)
+
+	var (
+		mutex  sync.Mutex
+		active int
+		most   int
+		opened int
+		wg     sync.WaitGroup
+	)
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lease, err := s.Acquire(context.Background(), server.URL, "user")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer lease.Release()
+
+			mutex.Lock()
+			active++
+			most = max(most, active)
+			if lease.New {
+				opened++
+			}
+			mutex.Unlock()
+
+			if _, err := lease.Page.Eval(`() => document.title`); err != nil {
+				t.Error(err)
+			}
+			time.Sleep(20 * time.Millisecond)
+
+			mutex.Lock()
+			active--
+			mutex.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if most != 1 {
+		t.Errorf("%d callers held the same tag at once, want 1", most)
+	}
+	if opened != 1 {
+		t.Errorf("opened %d pages for one tag, want 1", opened)
+	}
+}
+
+func TestLeasedPageIsNotEvicted(t *testing.T) {
+	s := newTestService(t, Options{MaxPages: 1, IdleTimeout: time.Minute})
+	server := newTestServer(t)
+
+	first, err := s.Acquire(context.Background(), server.URL, "first")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 借用中的分頁不因閒置或數量上限被關閉
+	s.closeIdle(time.Now().Add(time.Hour))
+	if !s.hasPage("first") {
+		t.Fatal("idle cleanup closed a leased page")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	if _, err := s.Acquire(ctx, server.URL, "second"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Acquire while the only page is leased = %v, want to wait until the deadline", err)
+	}
+	if _, err := first.Page.Eval(`() => document.title`); err != nil {
+		t.Fatalf("leased page unusable after a pending acquire: %v", err)
+	}
+
+	// 歸還後才移除最久未使用的分頁，讓出位置給新標籤
+	acquired := make(chan *Lease)
+	go func() {
+		lease, err := s.Acquire(context.Background(), server.URL, "second")
+		if err != nil {
+			t.Error(err)
+		}
+		acquired <- lease
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	first.Release()
+
+	second := <-acquired
+	if second == nil {
+		t.FailNow()
+	}
+	defer second.Release()
+	if !second.New {
+		t.Error("second tag must get a new page")
+	}
+	if s.hasPage("first") {
+		t.Error("released page must be evicted to stay within MaxPages")
+	}
+}
+
+// 加入尚未開啟分頁的項目，不需瀏覽器即可測試借用與移除邏輯
+func (s *BrowserService) addEntry(tag string, leased bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	entry := &pageEntry{tag: tag, leased: leased}
+	entry.element = s.lru.PushFront(entry)
+	s.pages[tag] = entry
+}
+
+func TestTryAcquireLeasesOneTagToOneCaller(t *testing.T) {
+	s := NewBrowserService(Options{MaxPages: 2})
+	defer s.Close()
+	s.addEntry("user", false)
+
+	var (
+		wg     sync.WaitGroup
+		mutex  sync.Mutex
+		leases []*Lease
+	)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if lease, ok := s.TryAcquire("user"); ok {
+				mutex.Lock()
+				leases = append(leases, lease)
+				mutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(leases) != 1 {
+		t.Fatalf("%d callers leased the same tag, want 1", len(leases))
+	}
+	leases[0].Release()
+	lease, ok := s.TryAcquire("user")
+	if !ok {
+		t.Fatal("released page must be leasable again")
+	}
+	lease.Release()
+}
+
+func TestReserveEvictsOnlyIdlePages(t *testing.T) {
+	s := NewBrowserService(Options{MaxPages: 2})
+	defer s.Close()
+	s.addEntry("leased", true)
+	s.addEntry("idle", false)
+
+	s.mutex.Lock()
+	evicted, ok := s.reserve()
+	s.mutex.Unlock()
+	if !ok || len(evicted) != 1 || evicted[0].tag != "idle" {
+		t.Fatalf("reserve() = %v, %v, want the idle page evicted", evicted, ok)
+	}
+
+	s.addEntry("another", true)
+	s.mutex.Lock()
+	_, ok = s.reserve()
+	s.mutex.Unlock()
+	if ok {
+		t.Fatal("reserve() must not evict leased pages")
+	}
+	if !s.hasPage("leased") || !s.hasPage("another") {
+		t.Error("leased pages were removed")
+	}
+}
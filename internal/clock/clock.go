@@ -0,0 +1,26 @@
+package clock
+
+import (
+	"time"
+
+	"github.com/tian841224/crawler_sportcenter/internal/types"
+)
+
+// Clock 提供目前時間，可替換為網站伺服器時間
+type Clock interface {
+	Now() time.Time
+}
+
+var _ Clock = SystemClock{}
+
+// SystemClock 本機時間
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// NextDateByWeekday 依時鐘的今天取得最近一個符合星期的日期（含今天）
+func NextDateByWeekday(c Clock, weekday time.Weekday) time.Time {
+	return types.NextDateByWeekday(weekday, types.DateOnly(c.Now()))
+}
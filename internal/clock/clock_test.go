@@ -0,0 +1,31 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time {
+	return time.Time(c)
+}
+
+func TestNextDateByWeekday(t *testing.T) {
+	// 2026-11-02 為星期一，本機與網站時間可能在午夜前後不同日
+	now := fixedClock(time.Date(2026, 11, 2, 23, 59, 59, 0, time.Local))
+
+	tests := []struct {
+		weekday time.Weekday
+		want    time.Time
+	}{
+		{time.Monday, time.Date(2026, 11, 2, 0, 0, 0, 0, time.Local)},
+		{time.Tuesday, time.Date(2026, 11, 3, 0, 0, 0, 0, time.Local)},
+		{time.Sunday, time.Date(2026, 11, 8, 0, 0, 0, 0, time.Local)},
+	}
+	for _, tt := range tests {
+		if got := NextDateByWeekday(now, tt.weekday); !got.Equal(tt.want) {
+			t.Errorf("NextDateByWeekday(%v) = %v, want %v", tt.weekday, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package clock
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
+	"github.com/tian841224/crawler_sportcenter/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// Syncer 可主動與伺服器校時的時鐘
+type Syncer interface {
+	Sync(ctx context.Context) error
+}
+
+// PageWatcher 可從頁面回應取得伺服器時間的時鐘
+type PageWatcher interface {
+	WatchPage(page *rod.Page)
+}
+
+var (
+	_ Clock       = (*ServerClock)(nil)
+	_ Syncer      = (*ServerClock)(nil)
+	_ PageWatcher = (*ServerClock)(nil)
+)
+
+// 保留的樣本數
+const maxSamples = 32
+
+// 一筆樣本：送出與收到回應的本機時間，以及回應的 Date 標頭
+type sample struct {
+	sentAt     time.Time
+	receivedAt time.Time
+	serverDate time.Time
+}
+
+// ServerClock 以網站回應的 Date 標頭估算伺服器時間
+// Date 標頭只到秒，因此以多筆樣本的可能範圍取交集來縮小誤差
+type ServerClock struct {
+	url     string
+	host    string
+	client  *http.Client
+	mutex   sync.RWMutex
+	samples []sample
+	offset  time.Duration // 伺服器時間減本機時間
+	jitter  time.Duration // 估算誤差範圍（正負）
+	synced  bool
+}
+
+func NewServerClock(rawURL string, client *http.Client) (*ServerClock, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url %s: %w", rawURL, err)
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 5 * time.Second}
+	}
+	return &ServerClock{
+		url:    rawURL,
+		host:   parsed.Hostname(),
+		client: client,
+	}, nil
+}
+
+// Now 伺服器目前時間，尚未校時時為本機時間
+func (c *ServerClock) Now() time.Time {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return time.Now().Add(c.offset)
+}
+
+// Offset 伺服器時間與本機時間的差
+func (c *ServerClock) Offset() time.Duration {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.offset
+}
+
+// Jitter 估算誤差範圍
+func (c *ServerClock) Jitter() time.Duration {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.jitter
+}
+
+// Synced 是否已有樣本
+func (c *ServerClock) Synced() bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.synced
+}
+
+// Sync 對網站送出數次 HEAD 請求取得樣本，請求間隔讓 Date 標頭跨越秒數邊界
+func (c *ServerClock) Sync(ctx context.Context) error {
+	const count = 8
+	var lastErr error
+	for i := 0; i < count; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Second / count * 3):
+			}
+		}
+		if err := c.sampleOnce(ctx); err != nil {
+			lastErr = err
+			logger.Log.Warn("校時請求失敗", zap.String("url", c.url), zap.Error(err))
+		}
+	}
+
+	if !c.Synced() {
+		return fmt.Errorf("校時失敗: %w", lastErr)
+	}
+	logger.Log.Info("伺服器校時完成", zap.Duration("offset", c.Offset()), zap.Duration("jitter", c.Jitter()))
+	return nil
+}
+
+func (c *ServerClock) sampleOnce(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, c.url, nil)
+	if err != nil {
+		return err
+	}
+
+	sentAt := time.Now()
+	resp, err := c.client.Do(req)
+	receivedAt := time.Now()
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	serverDate, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return fmt.Errorf("invalid Date header: %w", err)
+	}
+	c.AddSample(sentAt, receivedAt, serverDate)
+	return nil
+}
+
+// WatchPage 監聽頁面回應，從同網站回應的 Date 標頭取得樣本
+func (c *ServerClock) WatchPage(page *rod.Page) {
+	if err := (proto.NetworkEnable{}).Call(page); err != nil {
+		logger.Log.Warn("啟用網路事件失敗", zap.Error(err))
+		return
+	}
+
+	// 瀏覽器的請求時序為單調時間，以 requestWillBeSent 的實際時間換算
+	// 不使用收到事件時的本機時間，避免事件處理延遲造成誤差
+	var origin time.Time
+	go page.EachEvent(func(e *proto.NetworkRequestWillBeSent) {
+		origin = e.WallTime.Time().Add(-e.Timestamp.Duration())
+	}, func(e *proto.NetworkResponseReceived) {
+		if e.Response == nil || e.Response.FromDiskCache || e.Response.FromServiceWorker || origin.IsZero() {
+			return
+		}
+		parsed, err := url.Parse(e.Response.URL)
+		if err != nil || parsed.Hostname() != c.host {
+			return
+		}
+
+		dateHeader := ""
+		for key, value := range e.Response.Headers {
+			if http.CanonicalHeaderKey(key) == "Date" {
+				dateHeader = value.Str()
+				break
+			}
+		}
+		serverDate, err := http.ParseTime(dateHeader)
+		if err != nil {
+			return
+		}
+
+		sentAt, receivedAt, ok := requestTimes(origin, e.Response.Timing)
+		if !ok {
+			return
+		}
+		c.AddSample(sentAt, receivedAt, serverDate)
+	})()
+}
+
+// 由請求時序推算送出請求與收到回應標頭的實際時間
+// origin 為瀏覽器單調時間零點對應的實際時間
+func requestTimes(origin time.Time, timing *proto.NetworkResourceTiming) (time.Time, time.Time, bool) {
+	if timing == nil || timing.RequestTime <= 0 || timing.SendStart < 0 || timing.ReceiveHeadersEnd <= timing.SendStart {
+		return time.Time{}, time.Time{}, false
+	}
+
+	requestTime := origin.Add(proto.MonotonicTime(timing.RequestTime).Duration())
+	sentAt := requestTime.Add(time.Duration(timing.SendStart * float64(time.Millisecond)))
+	receivedAt := requestTime.Add(time.Duration(timing.ReceiveHeadersEnd * float64(time.Millisecond)))
+	return sentAt, receivedAt, true
+}
+
+// AddSample 加入樣本並重新估算
+func (c *ServerClock) AddSample(sentAt, receivedAt, serverDate time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.samples = append(c.samples, sample{sentAt: sentAt, receivedAt: receivedAt, serverDate: serverDate})
+	if len(c.samples) > maxSamples {
+		c.samples = c.samples[len(c.samples)-maxSamples:]
+	}
+	c.offset, c.jitter = estimate(c.samples)
+	c.synced = true
+}
+
+// 估算時間差
+// 伺服器在 sentAt 與 receivedAt 之間產生回應，且真實時間落在 [Date, Date+1s)
+// 因此每筆樣本的時間差範圍為 [Date-receivedAt, Date+1s-sentAt)，取所有樣本的交集
+func estimate(samples []sample) (time.Duration, time.Duration) {
+	lower := time.Duration(-1 << 63)
+	upper := time.Duration(1<<63 - 1)
+	for _, s := range samples {
+		lower = max(lower, s.serverDate.Sub(s.receivedAt))
+		upper = min(upper, s.serverDate.Add(time.Second).Sub(s.sentAt))
+	}
+	if lower <= upper {
+		return (lower + upper) / 2, (upper - lower) / 2
+	}
+
+	// 交集為空（網路延遲異常或本機時鐘跳動），改用各樣本中點的中位數
+	mids := make([]time.Duration, 0, len(samples))
+	for _, s := range samples {
+		low := s.serverDate.Sub(s.receivedAt)
+		high := s.serverDate.Add(time.Second).Sub(s.sentAt)
+		mids = append(mids, (low+high)/2)
+	}
+	sort.Slice(mids, func(i, j int) bool { return mids[i] < mids[j] })
+	median := mids[len(mids)/2]
+
+	var spread time.Duration
+	for _, mid := range mids {
+		if d := mid - median; d > spread {
+			spread = d
+		} else if -d > spread {
+			spread = -d
+		}
+	}
+	return median, spread
+}
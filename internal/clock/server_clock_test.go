@@ -0,0 +1,176 @@
+package clock
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-rod/rod/lib/proto"
+	"github.com/tian841224/crawler_sportcenter/pkg/logger"
+	"go.uber.org/zap"
+)
+
+func TestMain(m *testing.M) {
+	logger.Log = zap.NewNop()
+	os.Exit(m.Run())
+}
+
+// 回應的 Date 標頭比本機時間快 skew
+func newSkewedServer(t *testing.T, skew time.Duration) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Date", time.Now().Add(skew).UTC().Format(http.TimeFormat))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestServerClockSync(t *testing.T) {
+	if testing.Short() {
+		t.Skip("sync waits between requests")
+	}
+
+	for _, skew := range []time.Duration{0, 42*time.Second + 300*time.Millisecond, -3*time.Minute - 700*time.Millisecond} {
+		t.Run(skew.String(), func(t *testing.T) {
+			server := newSkewedServer(t, skew)
+			clk, err := NewServerClock(server.URL, server.Client())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := clk.Sync(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			// 交集的誤差範圍需涵蓋實際時間差，且遠小於 Date 標頭的一秒精度
+			if diff := (clk.Offset() - skew).Abs(); diff > clk.Jitter()+50*time.Millisecond {
+				t.Errorf("offset = %v, want %v ± %v", clk.Offset(), skew, clk.Jitter())
+			}
+			if clk.Jitter() > 500*time.Millisecond {
+				t.Errorf("jitter = %v, want samples across second boundaries to narrow it", clk.Jitter())
+			}
+			if got := clk.Now().Sub(time.Now().Add(skew)).Abs(); got > time.Second {
+				t.Errorf("Now() differs from server time by %v", got)
+			}
+		})
+	}
+}
+
+func TestServerClockSyncFailsWithoutSamples(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	clk, err := NewServerClock(server.URL, server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	if err := clk.Sync(ctx); err == nil {
+		t.Fatal("Sync against a closed server must fail")
+	}
+	if clk.Synced() {
+		t.Error("clock must not be synced without samples")
+	}
+}
+
+// 以固定的時間差產生樣本，Date 標頭捨去到秒
+func skewedSample(local time.Time, rtt, offset time.Duration) sample {
+	serverAt := local.Add(rtt / 2).Add(offset)
+	return sample{
+		sentAt:     local,
+		receivedAt: local.Add(rtt),
+		serverDate: serverAt.Truncate(time.Second),
+	}
+}
+
+func TestEstimate(t *testing.T) {
+	base := time.Date(2026, 11, 2, 7, 59, 50, 0, time.UTC)
+	offset := 1500 * time.Millisecond
+
+	tests := []struct {
+		name      string
+		samples   []sample
+		want      time.Duration
+		maxJitter time.Duration
+		tolerance time.Duration
+	}{
+		{
+			name:      "single sample spans one second",
+			samples:   []sample{skewedSample(base, 20*time.Millisecond, offset)},
+			want:      offset,
+			maxJitter: 520 * time.Millisecond,
+			tolerance: 520 * time.Millisecond,
+		},
+		{
+			name: "samples across second boundaries intersect",
+			samples: []sample{
+				skewedSample(base.Add(100*time.Millisecond), 20*time.Millisecond, offset),
+				skewedSample(base.Add(475*time.Millisecond), 20*time.Millisecond, offset),
+				skewedSample(base.Add(850*time.Millisecond), 20*time.Millisecond, offset),
+				skewedSample(base.Add(1225*time.Millisecond), 20*time.Millisecond, offset),
+				skewedSample(base.Add(1490*time.Millisecond), 20*time.Millisecond, offset),
+			},
+			want:      offset,
+			maxJitter: 30 * time.Millisecond,
+			tolerance: 30 * time.Millisecond,
+		},
+		{
+			// 第二筆樣本與其他樣本相差數秒，交集為空時改用中位數
+			name: "empty intersection falls back to median",
+			samples: []sample{
+				skewedSample(base, 20*time.Millisecond, offset),
+				skewedSample(base.Add(time.Second), 20*time.Millisecond, offset+5*time.Second),
+				skewedSample(base.Add(2*time.Second), 20*time.Millisecond, offset),
+			},
+			want:      offset,
+			maxJitter: 6 * time.Second,
+			tolerance: 520 * time.Millisecond,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, jitter := estimate(tt.samples)
+			if diff := (got - tt.want).Abs(); diff > tt.tolerance {
+				t.Errorf("offset = %v, want %v ± %v", got, tt.want, tt.tolerance)
+			}
+			if jitter > tt.maxJitter {
+				t.Errorf("jitter = %v, want <= %v", jitter, tt.maxJitter)
+			}
+		})
+	}
+}
+
+func TestRequestTimes(t *testing.T) {
+	origin := time.Date(2026, 11, 2, 0, 0, 0, 0, time.UTC)
+
+	sentAt, receivedAt, ok := requestTimes(origin, &proto.NetworkResourceTiming{
+		RequestTime:       100.5,
+		SendStart:         10,
+		ReceiveHeadersEnd: 60,
+	})
+	if !ok {
+		t.Fatal("valid timing rejected")
+	}
+	if want := origin.Add(100*time.Second + 510*time.Millisecond); !sentAt.Equal(want) {
+		t.Errorf("sentAt = %v, want %v", sentAt, want)
+	}
+	if want := origin.Add(100*time.Second + 560*time.Millisecond); !receivedAt.Equal(want) {
+		t.Errorf("receivedAt = %v, want %v", receivedAt, want)
+	}
+
+	for _, timing := range []*proto.NetworkResourceTiming{
+		nil,
+		{RequestTime: 100, SendStart: -1, ReceiveHeadersEnd: 60},
+		{RequestTime: 100, SendStart: 10, ReceiveHeadersEnd: 5},
+	} {
+		if _, _, ok := requestTimes(origin, timing); ok {
+			t.Errorf("timing %+v must be rejected", timing)
+		}
+	}
+}
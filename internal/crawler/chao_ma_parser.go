@@ -0,0 +1,84 @@
+package crawler
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/tian841224/crawler_sportcenter/internal/types"
+)
+
+// 朝馬頁面解析，只處理 HTML，不依賴瀏覽器或網路
+
+// 預約按鈕參數 (例如 Step3Action(1112,20))
+var step3ActionPattern = regexp.MustCompile(`Step3Action\((\d+),\s*(\d+)\)`)
+
+// ParseChaoMaSlots 解析指定日期的場地列表頁，只保留有預約按鈕的場地
+// 頁面沒有場地列表（登入逾時、錯誤頁或改版）時回傳錯誤，避免誤判為沒有空場地
+func ParseChaoMaSlots(r io.Reader, date time.Time) ([]types.Slot, error) {
+	doc, err := goquery.NewDocumentFromReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	table := doc.Find("#ContentPlaceHolder1_Step2_data")
+	if table.Length() == 0 {
+		return nil, fmt.Errorf("找不到場地列表")
+	}
+
+	// 每列依序為 時段、場地、價格、預約按鈕，可預約的場地才有 Step3Action
+	slots := []types.Slot{}
+	table.Find("tr").Each(func(_ int, row *goquery.Selection) {
+		cells := row.Find("td")
+		button := row.Find(`[onclick*="Step3Action"]`)
+		if cells.Length() < 3 || button.Length() == 0 {
+			return
+		}
+
+		matches := step3ActionPattern.FindStringSubmatch(button.AttrOr("onclick", ""))
+		if len(matches) < 3 {
+			return
+		}
+		courtID, _ := strconv.Atoi(matches[1])
+		hour, _ := strconv.Atoi(matches[2])
+
+		// 時段文字無法解析時，以 QTime（開始的小時）推算
+		start, end, err := types.ParseTimeRange(date, strings.TrimSpace(cells.Eq(0).Text()))
+		if err != nil {
+			start = types.DateOnly(date).Add(time.Duration(hour) * time.Hour)
+			end = start.Add(time.Hour)
+		}
+
+		price := types.ParsePrice(cells.Eq(2).Text())
+		slots = append(slots, types.Slot{
+			VenueID:   types.VenueChaoMa,
+			CourtID:   courtID,
+			CourtName: strings.TrimSpace(cells.Eq(1).Text()),
+			Start:     start,
+			End:       end,
+			Price:     price,
+			Params:    types.BookingParams{CourtID: courtID, Date: types.DateOnly(date), Period: hour, Price: price},
+		})
+	})
+	return slots, nil
+}
+
+// ParseChaoMaBookingResult 由預約後顯示的結果視窗判斷是否預約成功
+// 沒有結果視窗時無法判斷，回傳錯誤
+func ParseChaoMaBookingResult(r io.Reader) (bool, error) {
+	doc, err := goquery.NewDocumentFromReader(r)
+	if err != nil {
+		return false, err
+	}
+
+	popup := doc.Find(".swal2-popup")
+	if popup.Length() == 0 {
+		return false, fmt.Errorf("找不到預約結果")
+	}
+	message := popup.Find(".swal2-title, .swal2-html-container, #swal2-content").Text()
+	return strings.Contains(message, "預約成功"), nil
+}
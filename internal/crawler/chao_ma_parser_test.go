@@ -0,0 +1,78 @@
+package crawler
+
+import (
+	"testing"
+
+	"github.com/tian841224/crawler_sportcenter/internal/types"
+)
+
+func TestParseChaoMaSlots(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    []types.Slot
+		wantErr bool
+	}{
+		{
+			fixture: "chao_ma_evening.html",
+			want: []types.Slot{
+				{VenueID: types.VenueChaoMa, CourtID: 1112, CourtName: "羽球1", Start: fixtureAt(3, 19), End: fixtureAt(3, 20), Price: 400,
+					Params: types.BookingParams{CourtID: 1112, Date: fixtureDate(3), Period: 19, Price: 400}},
+				{VenueID: types.VenueChaoMa, CourtID: 1121, CourtName: "羽球10", Start: fixtureAt(3, 19), End: fixtureAt(3, 20), Price: 400,
+					Params: types.BookingParams{CourtID: 1121, Date: fixtureDate(3), Period: 19, Price: 400}},
+				{VenueID: types.VenueChaoMa, CourtID: 1113, CourtName: "羽球2", Start: fixtureAt(3, 20), End: fixtureAt(3, 21), Price: 400,
+					Params: types.BookingParams{CourtID: 1113, Date: fixtureDate(3), Period: 20, Price: 400}},
+			},
+		},
+		{fixture: "chao_ma_full.html", want: []types.Slot{}},
+		// 登入逾時被導回登入頁時沒有場地列表，不可視為沒有空場地
+		{fixture: "chao_ma_login.html", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			got, err := ParseChaoMaSlots(openFixture(t, tt.fixture), fixtureDate(3))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got %+v, want an error for a page without the court list", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d slots, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i := range tt.want {
+				if !slotEqual(got[i], tt.want[i]) {
+					t.Errorf("slot %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseChaoMaBookingResult(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    bool
+		wantErr bool
+	}{
+		{fixture: "chao_ma_booked.html", want: true},
+		// 頁面其他位置出現「預約成功」不算成功，只看結果視窗
+		{fixture: "chao_ma_booking_failed.html", want: false},
+		{fixture: "chao_ma_evening.html", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			got, err := ParseChaoMaBookingResult(openFixture(t, tt.fixture))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("success = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
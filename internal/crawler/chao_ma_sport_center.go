@@ -1,88 +1,357 @@
 package crawler
 
 import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-rod/rod"
 	"github.com/go-rod/rod/lib/proto"
 	"github.com/tian841224/crawler_sportcenter/internal/browser"
+	"github.com/tian841224/crawler_sportcenter/internal/types"
 	"github.com/tian841224/crawler_sportcenter/pkg/logger"
+	"go.uber.org/zap"
 )
 
+var _ SportCenterProvider = (*ChaoMaSportCenterService)(nil)
+
 type ChaoMaSportCenterService struct {
-	browserService browser.BrowserService
+	browserService *browser.BrowserService
+	credentials    CredentialResolver
 	Chao_Ma_Url    string // 朝馬運動中心網址
+	bookingURL     string // 場地預約網址
+	paymentURL     string // 付款網址
 }
 
-func NewChaoMaSportCenterService(browserService browser.BrowserService) *ChaoMaSportCenterService {
+func NewChaoMaSportCenterService(browserService *browser.BrowserService, credentials CredentialResolver) *ChaoMaSportCenterService {
 	return &ChaoMaSportCenterService{
 		browserService: browserService,
+		credentials:    credentials,
 		Chao_Ma_Url:    "https://scr.cyc.org.tw/tp11.aspx?module=login_page&files=login",
+		bookingURL:     "https://scr.cyc.org.tw/tp11.aspx?module=net_booking&files=booking_place",
+		paymentURL:     "https://scr.cyc.org.tw/tp11.aspx?module=member&files=orderx_mt",
+	}
+}
+
+func (s *ChaoMaSportCenterService) ID() types.VenueID {
+	return types.VenueChaoMa
+}
+
+func (s *ChaoMaSportCenterService) Name() string {
+	return "朝馬運動中心"
+}
+
+// 目前僅支援羽球場
+func (s *ChaoMaSportCenterService) GetFacilities() []types.Facility {
+	return []types.Facility{{ID: "badminton", Name: "羽球"}}
+}
+
+func (s *ChaoMaSportCenterService) GetPaymentURL() string {
+	return s.paymentURL
+}
+
+func (s *ChaoMaSportCenterService) GetAvailableTimeSlots(date time.Time, time_slot int, tag string) ([]types.Slot, error) {
+	timeSlotCode := types.TimeSlotCode(time_slot)
+
+	var targetSlot []types.Slot
+	err := s.withPage(tag, func(page *rod.Page) error {
+		if err := s.selectDateAndPeriod(page, date, timeSlotCode.DayPeriod()); err != nil {
+			return err
+		}
+
+		cleanSlots, err := s.getAllAvailableTimeSlots(page, date)
+		if err != nil {
+			return err
+		}
+
+		targetSlot = s.findAvailableCourtsByTimeSlot(cleanSlots, timeSlotCode)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return targetSlot, nil
+}
+
+// 朝馬每次查詢皆直接以網址切換日期，排程與手動查詢流程相同
+func (s *ChaoMaSportCenterService) GetAvailableTimeSlotsForSchedule(date time.Time, time_slot int, tag string) ([]types.Slot, error) {
+	return s.GetAvailableTimeSlots(date, time_slot, tag)
+}
+
+// 朝馬沒有提供可預約日期列表
+func (s *ChaoMaSportCenterService) GetBookableDates(tag string) ([]time.Time, error) {
+	return nil, ErrNotSupported
+}
+
+func (s *ChaoMaSportCenterService) BookCourt(targetSlot []types.Slot, pref types.CourtPreference, tag string) (*types.Slot, error) {
+	var booked *types.Slot
+	err := s.withPage(tag, func(page *rod.Page) error {
+		var err error
+		booked, err = s.bookCourt(page, targetSlot, pref)
+		return err
+	})
+	return booked, err
+}
+
+// 依場地偏好逐一嘗試預約
+func (s *ChaoMaSportCenterService) bookCourt(page *rod.Page, targetSlot []types.Slot, pref types.CourtPreference) (*types.Slot, error) {
+	for _, slot := range pref.Apply(targetSlot) {
+		// 預約參數為 Step3Action 的場地與時段，加上查詢日期
+		params := slot.Params
+		if params.CourtID == 0 || params.Date.IsZero() {
+			logger.Log.Error("無法解析預約參數: " + params.String())
+			continue
+		}
+
+		bookURL := fmt.Sprintf("%s&StepFlag=25&QPid=%d&QTime=%d&PT=1&D=%s",
+			s.bookingURL, params.CourtID, params.Period, params.Date.Format("2006/01/02"))
+		if err := page.Navigate(bookURL); err != nil {
+			logger.Log.Error(fmt.Sprintf("前往預約頁面失敗: %s", err))
+			continue
+		}
+		if err := waitStable(page); err != nil {
+			logger.Log.Error(err.Error())
+			continue
+		}
+
+		// 預約確認視窗
+		if err := s.clickConfirmDialog(page); err != nil {
+			logger.Log.Error(err.Error())
+			continue
+		}
+
+		success, err := s.readBookingResult(page)
+		if err != nil {
+			logger.Log.Error(fmt.Sprintf("讀取預約結果失敗: %s", err))
+			continue
+		}
+		if success {
+			logger.Log.Info(fmt.Sprintf("成功預約場地：%s，時間：%s", slot.CourtName, slot.TimeRange()))
+			return &slot, nil
+		}
+		logger.Log.Error(fmt.Sprintf("預約場地 %s 失敗", slot.CourtName))
+	}
+
+	return nil, fmt.Errorf("所有場地預約嘗試均失敗")
+}
+
+// 等待預約結果視窗並判斷是否成功，讀取後關閉視窗
+func (s *ChaoMaSportCenterService) readBookingResult(page *rod.Page) (bool, error) {
+	if _, err := page.Timeout(10 * time.Second).Element(".swal2-popup"); err != nil {
+		return false, fmt.Errorf("找不到預約結果: %w", err)
+	}
+
+	html, err := page.HTML()
+	if err != nil {
+		return false, err
+	}
+	success, err := ParseChaoMaBookingResult(strings.NewReader(html))
+	if err != nil {
+		return false, err
+	}
+
+	if err := s.clickConfirmDialog(page); err != nil {
+		logger.Log.Warn("關閉預約結果視窗失敗: " + err.Error())
 	}
+	return success, nil
 }
 
-// 爬蟲朝馬運動中心
-func (s *ChaoMaSportCenterService) CrawlerChaoMa() error {
-	// 建立新頁面
-	page, err := s.browserService.GetPage(s.Chao_Ma_Url, "")
+// 朝馬尚未支援查詢會員訂單
+func (s *ChaoMaSportCenterService) GetOrders(tag string) ([]types.Order, error) {
+	return nil, ErrNotSupported
+}
+
+func (s *ChaoMaSportCenterService) CancelBooking(order types.Order, tag string) error {
+	return ErrNotSupported
+}
+
+// 借用已登入的頁面執行操作，操作途中被導回登入頁時重新登入後再試一次
+func (s *ChaoMaSportCenterService) withPage(tag string, fn func(page *rod.Page) error) error {
+	lease, err := s.preparePage(tag)
 	if err != nil {
-		logger.Log.Error("無法創建新頁面: " + err.Error())
+		return err
+	}
+	defer lease.Release()
+
+	err = fn(lease.Page)
+	if err == nil || !s.isLoggedOut(lease.Page) {
 		return err
 	}
 
-	// 讀取網站
+	logger.Log.Warn("登入已逾時，重新登入", zap.String("tag", tag), zap.Error(err))
+	if err = s.relogin(lease.Page, tag); err == nil {
+		err = fn(lease.Page)
+	}
+	if err != nil {
+		lease.Discard()
+	}
+	return err
+}
+
+// 借用該標籤的頁面，新分頁或已被登出的頁面先登入，使用完畢需歸還
+func (s *ChaoMaSportCenterService) preparePage(tag string) (*browser.Lease, error) {
+	lease, err := s.browserService.Acquire(context.Background(), s.Chao_Ma_Url, pageTag(s.ID(), tag))
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case lease.New:
+		err = s.loginWithCredential(lease.Page, tag)
+	case s.isLoggedOut(lease.Page):
+		logger.Log.Warn("登入已逾時，重新登入", zap.String("tag", tag))
+		err = s.relogin(lease.Page, tag)
+	default:
+		// 如果頁面已存在且仍在登入狀態，跳過登入
+		return lease, nil
+	}
+
+	// 登入失敗時關閉分頁，避免下次沿用停在中途的頁面
+	if err != nil {
+		lease.Discard()
+		return nil, err
+	}
+	return lease, nil
+}
+
+// 是否已被登出：網站登入逾時後會導回登入頁，或頁面上出現登入表單
+func (s *ChaoMaSportCenterService) isLoggedOut(page *rod.Page) bool {
+	info, err := page.Info()
+	if err == nil && strings.Contains(info.URL, "module=login_page") {
+		return true
+	}
+
+	has, _, err := page.Has("#ContentPlaceHolder1_loginid")
+	return err == nil && has
+}
+
+// 回到登入頁重新登入
+func (s *ChaoMaSportCenterService) relogin(page *rod.Page, tag string) error {
 	if err := page.Navigate(s.Chao_Ma_Url); err != nil {
-		logger.Log.Error("無法讀取網站: " + err.Error())
 		return err
 	}
+	if err := waitStable(page); err != nil {
+		return err
+	}
+	return s.loginWithCredential(page, tag)
+}
+
+// 以使用者自己的帳密登入
+func (s *ChaoMaSportCenterService) loginWithCredential(page *rod.Page, tag string) error {
+	cred, err := s.credentials.Resolve(context.Background(), tag)
+	if err != nil {
+		return err
+	}
+	return s.login(page, cred)
+}
 
+// 執行登入
+func (s *ChaoMaSportCenterService) login(page *rod.Page, cred Credential) error {
 	logger.Log.Info("讀取網站")
 
-	// 等待並點擊防詐騙訊息按鈕
-	if err := page.MustElement("button.swal2-confirm.swal2-styled").Click(proto.InputMouseButtonLeft, 1); err != nil {
-		logger.Log.Error("無法點擊確認按鈕: " + err.Error())
+	// 點擊防詐騙訊息按鈕
+	if err := s.clickConfirmDialog(page); err != nil {
+		return err
 	}
 
-	logger.Log.Info("點擊防詐騙訊息按鈕")
-
 	// 等待表單元素載入
-	if err := page.MustElement("#ContentPlaceHolder1_loginid").WaitVisible(); err != nil {
+	account, err := findElement(page, "#ContentPlaceHolder1_loginid")
+	if err == nil {
+		err = account.Timeout(pageTimeout).WaitVisible()
+	}
+	if err != nil {
 		logger.Log.Error("無法找到登入表單: " + err.Error())
 		return err
 	}
 
-	logger.Log.Info("等待表單元素載入")
-
-	// 填寫身分證字號
-	if err := page.MustElement("#ContentPlaceHolder1_loginid").Input("L124035685"); err != nil {
+	if err := account.Input(cred.Account); err != nil {
 		logger.Log.Error("無法輸入身分證字號: " + err.Error())
 		return err
 	}
-
 	logger.Log.Info("填寫身分證字號")
 
-	// 填寫密碼
-	if err := page.MustElement("#loginpw").Input("j25319456"); err != nil {
+	password, err := findElement(page, "#loginpw")
+	if err == nil {
+		err = password.Input(cred.Password)
+	}
+	if err != nil {
 		logger.Log.Error("無法輸入密碼: " + err.Error())
 		return err
 	}
-
 	logger.Log.Info("填寫密碼")
 
-	// 點擊登入按鈕
-	if err := page.MustElement("#login_but").Click(proto.InputMouseButtonLeft, 1); err != nil {
+	button, err := findElement(page, "#login_but")
+	if err == nil {
+		err = button.Click(proto.InputMouseButtonLeft, 1)
+	}
+	if err != nil {
 		logger.Log.Error("無法點擊登入按鈕: " + err.Error())
 		return err
 	}
-
 	logger.Log.Info("點擊登入按鈕")
 
-	// 等待頁面載入完成
-	page.MustWaitStable()
+	return waitStable(page)
+}
 
-	// 點擊羽球圖片
-	if err := page.MustElement("img[src='img/ICON2/ico02-01.png']").Click(proto.InputMouseButtonLeft, 1); err != nil {
-		logger.Log.Error("無法點擊羽球圖片: " + err.Error())
-		return err
+// 點擊 SweetAlert 確認視窗，不存在或無法點擊時略過，只在點擊後頁面沒有回應時回傳錯誤
+func (s *ChaoMaSportCenterService) clickConfirmDialog(page *rod.Page) error {
+	button, err := page.Timeout(3 * time.Second).Element("button.swal2-confirm.swal2-styled")
+	if err != nil {
+		return nil
 	}
+	if err := button.CancelTimeout().Click(proto.InputMouseButtonLeft, 1); err != nil {
+		logger.Log.Error("無法點擊確認按鈕: " + err.Error())
+		return nil
+	}
+	logger.Log.Info("點擊確認按鈕")
+	return waitStable(page)
+}
 
-	logger.Log.Info("成功點擊羽球圖片")
+// 前往指定日期與時段（1=上午，2=下午，3=晚上）的羽球場列表
+func (s *ChaoMaSportCenterService) selectDateAndPeriod(page *rod.Page, date time.Time, period int) error {
+	listURL := fmt.Sprintf("%s&StepFlag=2&PT=1&D=%s&D2=%d", s.bookingURL, date.Format("2006/01/02"), period)
+	if err := page.Navigate(listURL); err != nil {
+		logger.Log.Error(fmt.Sprintf("前往場地列表失敗: %s", err))
+		return err
+	}
+	if err := waitStable(page); err != nil {
+		logger.Log.Error(err.Error())
+		return err
+	}
+	logger.Log.Info(fmt.Sprintf("已選擇日期 %s 時段 %d", date.Format("2006/01/02"), period))
 	return nil
 }
+
+// 取得列表中所有可預約的場地
+func (s *ChaoMaSportCenterService) getAllAvailableTimeSlots(page *rod.Page, date time.Time) ([]types.Slot, error) {
+	html, err := page.HTML()
+	if err != nil {
+		logger.Log.Error(fmt.Sprintf("讀取場地列表失敗: %s", err))
+		return nil, err
+	}
+
+	cleanSlots, err := ParseChaoMaSlots(strings.NewReader(html), date)
+	if err != nil {
+		logger.Log.Error(fmt.Sprintf("解析場地列表失敗: %s", err))
+		return nil, err
+	}
+
+	logger.Log.Info(fmt.Sprintf("找到 %d 個可預約時段", len(cleanSlots)))
+	return cleanSlots, nil
+}
+
+// 根據時段代碼查找可用場地
+func (s *ChaoMaSportCenterService) findAvailableCourtsByTimeSlot(slots []types.Slot, code types.TimeSlotCode) []types.Slot {
+	var availableCourts []types.Slot
+
+	for _, slot := range slots {
+		if slot.Matches(code) {
+			availableCourts = append(availableCourts, slot)
+		}
+	}
+
+	logger.Log.Info(fmt.Sprintf("找到 %d 個 %v 可預約時段", len(availableCourts), code))
+	return availableCourts
+}
@@ -0,0 +1,67 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/tian841224/crawler_sportcenter/internal/domain/user"
+	"github.com/tian841224/crawler_sportcenter/pkg/config"
+	"gorm.io/gorm"
+)
+
+// ErrCredentialNotSet 使用者尚未設定運動中心帳密
+var ErrCredentialNotSet = errors.New("尚未設定運動中心帳號密碼，請使用 /setting 設定")
+
+// Credential 運動中心登入帳密
+type Credential struct {
+	Account  string
+	Password string
+}
+
+// CredentialResolver 依標籤（Telegram 帳號）取得登入帳密
+type CredentialResolver interface {
+	Resolve(ctx context.Context, tag string) (Credential, error)
+}
+
+var _ CredentialResolver = (*UserCredentialResolver)(nil)
+
+type UserCredentialResolver struct {
+	user user.Service
+	cfg  config.Config
+}
+
+func NewUserCredentialResolver(user user.Service, cfg config.Config) *UserCredentialResolver {
+	return &UserCredentialResolver{
+		user: user,
+		cfg:  cfg,
+	}
+}
+
+// Resolve 優先使用使用者自己的帳密，僅管理員可退回使用設定檔帳密
+func (r *UserCredentialResolver) Resolve(ctx context.Context, tag string) (Credential, error) {
+	account, password, err := r.user.GetSportCenterCredential(ctx, tag)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return Credential{}, fmt.Errorf("get sport center credential: %w", err)
+	}
+
+	if err == nil && account != "" && password != "" {
+		return Credential{
+			Account:  account,
+			Password: password,
+		}, nil
+	}
+
+	if r.isAdmin(tag) && r.cfg.ID != "" && r.cfg.Password != "" {
+		return Credential{
+			Account:  r.cfg.ID,
+			Password: r.cfg.Password,
+		}, nil
+	}
+
+	return Credential{}, ErrCredentialNotSet
+}
+
+func (r *UserCredentialResolver) isAdmin(tag string) bool {
+	return r.cfg.AdminAccountID != "" && tag == r.cfg.AdminAccountID
+}
@@ -0,0 +1,270 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/tian841224/crawler_sportcenter/internal/clock"
+	"github.com/tian841224/crawler_sportcenter/internal/types"
+	"github.com/tian841224/crawler_sportcenter/pkg/logger"
+)
+
+// 南屯網站路徑，對應頁面上 next()、SelectDate()、Selecttime() 送出的網址
+const (
+	nantunBaseURL     = "https://nd01.xuanen.com.tw"
+	nantunLoginPath   = "/BPMember/BPMemberLogin"
+	nantunBookingPath = "/BPHome/BPHomeOrder"
+	nantunOrderPath   = "/BPMemberOrder/BPMemberOrder"
+)
+
+// 閒置超過此時間的登入狀態會被移除，下次查詢時重新登入
+const nantunHTTPSessionIdleTimeout = time.Hour
+
+// NantunHTTPClient 以 net/http 直接查詢南屯場地，不需開啟瀏覽器
+// 每個標籤（Telegram 帳號）使用獨立的 cookie jar 保存登入狀態
+type NantunHTTPClient struct {
+	baseURL     string
+	credentials CredentialResolver
+	clock       clock.Clock
+	timeout     time.Duration
+	idleTimeout time.Duration
+	mutex       sync.Mutex
+	sessions    map[string]*nantunHTTPSession
+}
+
+// 單一標籤的登入狀態
+type nantunHTTPSession struct {
+	client   *http.Client
+	mutex    sync.Mutex // 同一標籤的請求依序執行，避免同時重新登入
+	users    int        // 使用中的請求數，需持有 NantunHTTPClient.mutex
+	lastUsed time.Time
+}
+
+func NewNantunHTTPClient(credentials CredentialResolver, clk clock.Clock) *NantunHTTPClient {
+	return &NantunHTTPClient{
+		baseURL:     nantunBaseURL,
+		credentials: credentials,
+		clock:       clk,
+		timeout:     10 * time.Second,
+		idleTimeout: nantunHTTPSessionIdleTimeout,
+		sessions:    make(map[string]*nantunHTTPSession),
+	}
+}
+
+// GetAvailableTimeSlots 查詢指定日期與時段（1=上午，2=下午，3=晚上）的可預約場地
+func (c *NantunHTTPClient) GetAvailableTimeSlots(ctx context.Context, date time.Time, period int, tag string) ([]types.Slot, error) {
+	doc, err := c.fetchBookingList(ctx, date, period, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	cleanSlots := parseNantunSlots(doc)
+	logger.Log.Info(fmt.Sprintf("找到 %d 個可預約時段", len(cleanSlots)))
+	return cleanSlots, nil
+}
+
+// GetBookableDates 取得日期列中所有可選擇的日期
+func (c *NantunHTTPClient) GetBookableDates(ctx context.Context, tag string) ([]time.Time, error) {
+	doc, err := c.fetchBookingList(ctx, c.clock.Now(), 1, tag)
+	if err != nil {
+		return nil, err
+	}
+	return parseNantunBookableDates(doc), nil
+}
+
+// GetOrders 取得會員訂單
+func (c *NantunHTTPClient) GetOrders(ctx context.Context, tag string) ([]types.Order, error) {
+	doc, _, err := c.fetch(ctx, c.baseURL+nantunOrderPath, tag)
+	if err != nil {
+		return nil, err
+	}
+	if doc.Find("table").Length() == 0 {
+		return nil, fmt.Errorf("無法辨識會員訂單頁")
+	}
+	return parseNantunOrders(doc), nil
+}
+
+// KeepAlive 對已登入的標籤送出請求延長登入時效，登入逾時會自動重新登入
+// 尚未以 HTTP 查詢過的標籤沒有登入狀態，略過
+func (c *NantunHTTPClient) KeepAlive(ctx context.Context, tag string) error {
+	if !c.hasSession(tag) {
+		return nil
+	}
+	_, _, err := c.fetch(ctx, c.baseURL+nantunOrderPath, tag)
+	return err
+}
+
+// 取得場地列表頁
+func (c *NantunHTTPClient) fetchBookingList(ctx context.Context, date time.Time, period int, tag string) (*goquery.Document, error) {
+	query := url.Values{}
+	query.Set("PT", "1")
+	query.Set("D", date.Format(types.DateLayout))
+	query.Set("D2", fmt.Sprint(period))
+	listURL := c.baseURL + nantunBookingPath + "?" + query.Encode()
+
+	doc, finalURL, err := c.fetch(ctx, listURL, tag)
+	if err != nil {
+		return nil, err
+	}
+	// 沒有場地列表時無法分辨是沒有空場地還是頁面改版，回傳錯誤改用瀏覽器查詢
+	if doc.Find("div.listbackground").Length() == 0 {
+		return nil, fmt.Errorf("無法辨識場地列表頁: %s", finalURL)
+	}
+	return doc, nil
+}
+
+// 取得需登入的頁面，登入逾時會自動重新登入一次
+func (c *NantunHTTPClient) fetch(ctx context.Context, rawURL string, tag string) (*goquery.Document, *url.URL, error) {
+	session := c.acquireSession(tag)
+	defer c.releaseSession(session)
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+	client := session.client
+
+	for attempt := 0; attempt < 2; attempt++ {
+		doc, finalURL, err := c.get(ctx, client, rawURL)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// 被導回登入頁表示尚未登入或登入逾時
+		if strings.Contains(finalURL.Path, nantunLoginPath) || doc.Find("#txt_Account").Length() > 0 {
+			if attempt > 0 {
+				return nil, nil, fmt.Errorf("登入後仍被導回登入頁")
+			}
+			if err := c.login(ctx, client, doc, finalURL, tag); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+		return doc, finalURL, nil
+	}
+	return nil, nil, fmt.Errorf("無法取得頁面: %s", rawURL)
+}
+
+// 以登入頁的表單送出帳密，保留表單中的隱藏欄位（例如 __RequestVerificationToken）
+func (c *NantunHTTPClient) login(ctx context.Context, client *http.Client, doc *goquery.Document, pageURL *url.URL, tag string) error {
+	cred, err := c.credentials.Resolve(ctx, tag)
+	if err != nil {
+		return err
+	}
+
+	if doc.Find("#txt_Account").Length() == 0 {
+		loginURL := c.baseURL + nantunLoginPath
+		if doc, pageURL, err = c.get(ctx, client, loginURL); err != nil {
+			return err
+		}
+	}
+
+	form := doc.Find("#txt_Account").Closest("form")
+	if form.Length() == 0 {
+		return fmt.Errorf("找不到登入表單")
+	}
+
+	values := url.Values{}
+	form.Find("input").Each(func(_ int, input *goquery.Selection) {
+		name, exists := input.Attr("name")
+		if !exists || name == "" {
+			return
+		}
+		values.Set(name, input.AttrOr("value", ""))
+	})
+	values.Set(doc.Find("#txt_Account").AttrOr("name", "txt_Account"), cred.Account)
+	values.Set(doc.Find("#txt_Pass").AttrOr("name", "txt_Pass"), cred.Password)
+
+	action, err := pageURL.Parse(form.AttrOr("action", pageURL.String()))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, action.String(), strings.NewReader(values.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("登入失敗: %s", resp.Status)
+	}
+	logger.Log.Info("南屯 HTTP 登入完成")
+	return nil
+}
+
+func (c *NantunHTTPClient) get(ctx context.Context, client *http.Client, rawURL string) (*goquery.Document, *url.URL, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return nil, nil, fmt.Errorf("GET %s: %s", rawURL, resp.Status)
+	}
+
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return doc, resp.Request.URL, nil
+}
+
+func (c *NantunHTTPClient) hasSession(tag string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	_, exists := c.sessions[tag]
+	return exists
+}
+
+// 取得標籤專屬的 HTTP client 與登入狀態，使用完畢需呼叫 releaseSession
+// 取得時順便移除閒置過久的登入狀態，避免標籤只增不減
+func (c *NantunHTTPClient) acquireSession(tag string) *nantunHTTPSession {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.removeIdleSessions(c.clock.Now())
+
+	session, exists := c.sessions[tag]
+	if !exists {
+		jar, _ := cookiejar.New(nil)
+		session = &nantunHTTPSession{client: &http.Client{Jar: jar, Timeout: c.timeout}}
+		c.sessions[tag] = session
+	}
+	session.users++
+	return session
+}
+
+func (c *NantunHTTPClient) releaseSession(session *nantunHTTPSession) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	session.users--
+	session.lastUsed = c.clock.Now()
+}
+
+// 移除沒有使用中請求且閒置過久的登入狀態，需持有鎖
+func (c *NantunHTTPClient) removeIdleSessions(now time.Time) {
+	for tag, session := range c.sessions {
+		if session.users == 0 && now.Sub(session.lastUsed) >= c.idleTimeout {
+			delete(c.sessions, tag)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tian841224/crawler_sportcenter/pkg/logger"
+	"go.uber.org/zap"
+)
+
+func TestMain(m *testing.M) {
+	logger.Log = zap.NewNop()
+	os.Exit(m.Run())
+}
+
+// 以標籤作為帳號
+type fakeCredentials struct{}
+
+func (fakeCredentials) Resolve(ctx context.Context, tag string) (Credential, error) {
+	return Credential{Account: tag, Password: "secret"}, nil
+}
+
+const fakeLoginPage = `<html><body>
+<form method="post" action="/BPMember/BPMemberLogin">
+  <input type="hidden" name="__RequestVerificationToken" value="token">
+  <input id="txt_Account" name="txt_Account">
+  <input id="txt_Pass" name="txt_Pass" type="password">
+  <button class="CssLoginBtn" type="submit">登入</button>
+</form>
+</body></html>`
+
+// 首頁，包含前往預約頁面流程所需的按鈕與函式
+const fakeHomePage = `<html><body>
+<button id="Msg_Agree">同意</button>
+<div id="location">場地預約</div>
+<div class="CssAdImg" data-slick-index="0">羽球</div>
+<input type="checkbox" id="isRememberAcc">
+<script>
+function next(step) { if (step === undefined) location.href = '/BPHome/BPHomeOrder'; }
+function checkclick() {}
+</script>
+</body></html>`
+
+// 場地列表頁的函式，預約送出後前往確認頁，確認後前往完成頁
+const fakeBookingScript = `<div class="selectweek"></div>
+<script>
+function SelectDate(date) {}
+function Selecttime(period) {}
+function DoSubmit2(court, date, period, price) { location.href = '/BPHome/BPHomeOrder?tFlag=2'; }
+function DoSubmit3(date) { location.href = '/BPHome/BPHomeOrder?tFlag=3'; }
+</script>
+</body>`
+
+// 模擬南屯網站，未登入時導回登入頁，並記錄每個帳號的登入次數、同時登入數與完成的預約數
+type fakeNantunServer struct {
+	*httptest.Server
+	mutex    sync.Mutex
+	logins   map[string]int
+	inflight map[string]int
+	overlap  map[string]bool // 同一帳號曾同時登入
+	bookings int
+	listings []url.Values // 場地列表頁收到的查詢參數
+}
+
+func newFakeNantunServer(t *testing.T) *fakeNantunServer {
+	t.Helper()
+	orders, err := os.ReadFile(filepath.Join("testdata", "nantun_orders.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	listing, err := os.ReadFile(filepath.Join("testdata", "nantun_evening.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	listing = []byte(strings.Replace(string(listing), "</body>", fakeBookingScript, 1))
+
+	s := &fakeNantunServer{
+		logins:   make(map[string]int),
+		inflight: make(map[string]int),
+		overlap:  make(map[string]bool),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+nantunLoginPath, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(fakeLoginPage))
+	})
+	mux.HandleFunc("POST "+nantunLoginPath, func(w http.ResponseWriter, r *http.Request) {
+		account := r.FormValue("txt_Account")
+		s.mutex.Lock()
+		s.inflight[account]++
+		if s.inflight[account] > 1 {
+			s.overlap[account] = true
+		}
+		s.mutex.Unlock()
+
+		// 拉長登入時間，讓同時送出的登入重疊
+		time.Sleep(50 * time.Millisecond)
+
+		s.mutex.Lock()
+		s.inflight[account]--
+		s.logins[account]++
+		s.mutex.Unlock()
+
+		http.SetCookie(w, &http.Cookie{Name: "member", Value: account, Path: "/"})
+		http.Redirect(w, r, "/BPHome/BPHome", http.StatusFound)
+	})
+	mux.HandleFunc("GET /BPHome/BPHome", func(w http.ResponseWriter, r *http.Request) {
+		if _, err := r.Cookie("member"); err != nil {
+			http.Redirect(w, r, nantunLoginPath, http.StatusFound)
+			return
+		}
+		w.Write([]byte(fakeHomePage))
+	})
+	mux.HandleFunc("GET "+nantunBookingPath, func(w http.ResponseWriter, r *http.Request) {
+		if _, err := r.Cookie("member"); err != nil {
+			http.Redirect(w, r, nantunLoginPath, http.StatusFound)
+			return
+		}
+		s.mutex.Lock()
+		s.listings = append(s.listings, r.URL.Query())
+		s.mutex.Unlock()
+		if r.URL.Query().Get("tFlag") == "3" {
+			s.mutex.Lock()
+			s.bookings++
+			s.mutex.Unlock()
+			w.Write([]byte("<html><body>預約成功</body></html>"))
+			return
+		}
+		w.Write(listing)
+	})
+	mux.HandleFunc("GET "+nantunOrderPath, func(w http.ResponseWriter, r *http.Request) {
+		if _, err := r.Cookie("member"); err != nil {
+			http.Redirect(w, r, nantunLoginPath, http.StatusFound)
+			return
+		}
+		w.Write(orders)
+	})
+
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *fakeNantunServer) loginCount(account string) (int, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.logins[account], s.overlap[account]
+}
+
+type fixedClock struct {
+	now time.Time
+}
+
+func (c *fixedClock) Now() time.Time {
+	return c.now
+}
+
+func newTestHTTPClient(server *fakeNantunServer, clk *fixedClock) *NantunHTTPClient {
+	client := NewNantunHTTPClient(fakeCredentials{}, clk)
+	client.baseURL = server.URL
+	return client
+}
+
+func TestNantunHTTPClientGetAvailableTimeSlots(t *testing.T) {
+	server := newFakeNantunServer(t)
+	client := newTestHTTPClient(server, &fixedClock{now: fixtureAt(2, 10)})
+
+	got, err := client.GetAvailableTimeSlots(context.Background(), fixtureDate(3), 3, "100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 || got[0].CourtName != "羽球A場" || !got[0].Start.Equal(fixtureAt(3, 19)) {
+		t.Fatalf("got %+v, want the three evening courts", got)
+	}
+
+	query := server.listings[len(server.listings)-1]
+	if query.Get("D") != "2026-11-03" || query.Get("D2") != "3" {
+		t.Errorf("query = %v, want D=2026-11-03 D2=3", query)
+	}
+}
+
+func TestNantunHTTPClientGetBookableDatesUsesClock(t *testing.T) {
+	server := newFakeNantunServer(t)
+	client := newTestHTTPClient(server, &fixedClock{now: fixtureAt(2, 23)})
+
+	got, err := client.GetBookableDates(context.Background(), "100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 7 || !got[0].Equal(fixtureDate(2)) || !got[6].Equal(fixtureDate(8)) {
+		t.Fatalf("got %v, want 2026-11-02 to 2026-11-08", got)
+	}
+
+	// 以注入的時鐘決定查詢日期，不使用本機時間
+	query := server.listings[len(server.listings)-1]
+	if query.Get("D") != "2026-11-02" {
+		t.Errorf("queried date %s, want the clock's date 2026-11-02", query.Get("D"))
+	}
+}
+
+func TestNantunHTTPClientRemovesIdleSessions(t *testing.T) {
+	server := newFakeNantunServer(t)
+	clk := &fixedClock{now: fixtureAt(2, 10)}
+	client := newTestHTTPClient(server, clk)
+
+	if _, err := client.GetOrders(context.Background(), "100"); err != nil {
+		t.Fatal(err)
+	}
+	clk.now = clk.now.Add(nantunHTTPSessionIdleTimeout)
+	if _, err := client.GetOrders(context.Background(), "200"); err != nil {
+		t.Fatal(err)
+	}
+
+	if client.hasSession("100") {
+		t.Error("idle session must be removed")
+	}
+	if !client.hasSession("200") {
+		t.Error("active session must be kept")
+	}
+
+	// 移除後重新查詢需再次登入
+	if _, err := client.GetOrders(context.Background(), "100"); err != nil {
+		t.Fatal(err)
+	}
+	if logins, _ := server.loginCount("100"); logins != 2 {
+		t.Errorf("logins = %d, want a new login after the session was removed", logins)
+	}
+}
+
+func TestNantunHTTPClientSerializesLoginPerTag(t *testing.T) {
+	server := newFakeNantunServer(t)
+	client := newTestHTTPClient(server, &fixedClock{now: fixtureAt(2, 10)})
+
+	var wg sync.WaitGroup
+	for _, tag := range []string{"100", "100", "100", "100", "200", "200"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			orders, err := client.GetOrders(context.Background(), tag)
+			if err != nil {
+				t.Errorf("GetOrders(%s): %v", tag, err)
+				return
+			}
+			if len(orders) == 0 {
+				t.Errorf("GetOrders(%s) returned no orders", tag)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, account := range []string{"100", "200"} {
+		logins, overlap := server.loginCount(account)
+		if logins != 1 || overlap {
+			t.Errorf("account %s: logins = %d, overlap = %v, want a single serialized login", account, logins, overlap)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package crawler
+
+import (
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/tian841224/crawler_sportcenter/internal/types"
+)
+
+// 會員訂單頁解析
+// 欄位依表頭文字對應，欄位順序變動時仍可解析
+
+var (
+	orderDatePattern  = regexp.MustCompile(`\d{4}[/-]\d{1,2}[/-]\d{1,2}`)
+	orderTimePattern  = regexp.MustCompile(`\d{1,2}[:：]\d{2}\s*[-~～至]\s*\d{1,2}[:：]\d{2}`)
+	orderClockPattern = regexp.MustCompile(`\d{1,2}[:：]\d{2}`)
+)
+
+// 訂單欄位
+type orderColumn int
+
+const (
+	orderColumnUnknown orderColumn = iota
+	orderColumnID
+	orderColumnCourt
+	orderColumnDate
+	orderColumnTime
+	orderColumnPrice
+	orderColumnStatus
+	orderColumnDeadline
+)
+
+// 依表頭文字判斷欄位，期限須在狀態前判斷（例如「繳費期限」與「繳費狀態」）
+func detectOrderColumn(header string) orderColumn {
+	switch {
+	case strings.Contains(header, "期限") || strings.Contains(header, "截止"):
+		return orderColumnDeadline
+	case strings.Contains(header, "狀態"):
+		return orderColumnStatus
+	case strings.Contains(header, "編號") || strings.Contains(header, "訂單"):
+		return orderColumnID
+	case strings.Contains(header, "場地"):
+		return orderColumnCourt
+	case strings.Contains(header, "日期"):
+		return orderColumnDate
+	case strings.Contains(header, "時段") || strings.Contains(header, "時間"):
+		return orderColumnTime
+	case strings.Contains(header, "金額") || strings.Contains(header, "費用") || strings.Contains(header, "價格"):
+		return orderColumnPrice
+	}
+	return orderColumnUnknown
+}
+
+// ParseNantunOrders 解析會員訂單頁
+func ParseNantunOrders(r io.Reader) ([]types.Order, error) {
+	doc, err := goquery.NewDocumentFromReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return parseNantunOrders(doc), nil
+}
+
+// 訂單列，包含取消按鈕的動作
+type nantunOrderRow struct {
+	order        types.Order
+	cancelScript string // 取消按鈕的 onclick
+	cancelURL    string // 取消連結
+}
+
+func parseNantunOrders(doc *goquery.Document) []types.Order {
+	rows := parseNantunOrderRows(doc)
+	orders := make([]types.Order, 0, len(rows))
+	for _, row := range rows {
+		orders = append(orders, row.order)
+	}
+	return orders
+}
+
+func parseNantunOrderRows(doc *goquery.Document) []nantunOrderRow {
+	var orderRows []nantunOrderRow
+	doc.Find("table").Each(func(_ int, table *goquery.Selection) {
+		var columns []orderColumn
+		table.Find("tr").Each(func(_ int, row *goquery.Selection) {
+			// 第一個有 th 的列為表頭
+			if headers := row.Find("th"); headers.Length() > 0 {
+				columns = columns[:0]
+				headers.Each(func(_ int, th *goquery.Selection) {
+					columns = append(columns, detectOrderColumn(strings.TrimSpace(th.Text())))
+				})
+				return
+			}
+			if len(columns) == 0 {
+				return
+			}
+
+			cells := make(map[orderColumn]string)
+			row.Find("td").Each(func(i int, td *goquery.Selection) {
+				if i < len(columns) && columns[i] != orderColumnUnknown {
+					cells[columns[i]] = strings.TrimSpace(td.Text())
+				}
+			})
+
+			order, ok := newNantunOrder(cells)
+			if !ok {
+				return
+			}
+			orderRow := nantunOrderRow{order: order}
+			orderRow.cancelScript, orderRow.cancelURL = findCancelAction(row)
+			orderRows = append(orderRows, orderRow)
+		})
+	})
+	return orderRows
+}
+
+// 找出列中文字為「取消」的按鈕或連結
+func findCancelAction(row *goquery.Selection) (string, string) {
+	var script, link string
+	row.Find("a, button, input, div[onclick], span[onclick]").EachWithBreak(func(_ int, sel *goquery.Selection) bool {
+		label := strings.TrimSpace(sel.Text()) + sel.AttrOr("value", "")
+		if !strings.Contains(label, "取消") {
+			return true
+		}
+		if onclick := sel.AttrOr("onclick", ""); onclick != "" {
+			script = onclick
+			return false
+		}
+		if href := sel.AttrOr("href", ""); href != "" && href != "#" {
+			if code, isScript := strings.CutPrefix(href, "javascript:"); isScript {
+				script = code
+			} else {
+				link = href
+			}
+			return false
+		}
+		return true
+	})
+	return script, link
+}
+
+// 以欄位內容組成訂單，找不到日期或時段時略過
+func newNantunOrder(cells map[orderColumn]string) (types.Order, bool) {
+	// 日期與時段可能在同一欄
+	date, ok := parseOrderDate(cells[orderColumnDate] + " " + cells[orderColumnTime])
+	if !ok {
+		return types.Order{}, false
+	}
+	timeRange := orderTimePattern.FindString(cells[orderColumnTime] + " " + cells[orderColumnDate])
+	start, end, err := types.ParseTimeRange(date, timeRange)
+	if err != nil {
+		return types.Order{}, false
+	}
+
+	order := types.Order{
+		OrderID:   cells[orderColumnID],
+		CourtName: cells[orderColumnCourt],
+		Start:     start,
+		End:       end,
+		Price:     types.ParsePrice(cells[orderColumnPrice]),
+		Status:    parseOrderStatus(cells[orderColumnStatus]),
+	}
+
+	// 繳費期限可能只有日期，只有日期時視為當天結束前
+	if deadlineText := cells[orderColumnDeadline]; deadlineText != "" {
+		if deadline, ok := parseOrderDate(deadlineText); ok {
+			if clock := orderClockPattern.FindString(deadlineText); clock != "" {
+				if t, err := time.Parse("15:04", strings.ReplaceAll(clock, "：", ":")); err == nil {
+					deadline = deadline.Add(time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute)
+				}
+			} else {
+				deadline = deadline.AddDate(0, 0, 1).Add(-time.Minute)
+			}
+			order.PaymentDeadline = deadline
+		}
+	}
+	return order, true
+}
+
+func parseOrderDate(value string) (time.Time, bool) {
+	text := strings.ReplaceAll(orderDatePattern.FindString(value), "/", "-")
+	if text == "" {
+		return time.Time{}, false
+	}
+	date, err := time.ParseInLocation("2006-1-2", text, time.Local)
+	return date, err == nil
+}
+
+// 依狀態文字判斷付款狀態，無法判斷時回傳 OrderUnknown，由呼叫端略過
+func parseOrderStatus(value string) types.OrderStatus {
+	switch {
+	case strings.Contains(value, "逾期") || strings.Contains(value, "取消") && strings.Contains(value, "未"):
+		return types.OrderExpired
+	case strings.Contains(value, "取消"):
+		return types.OrderCancelled
+	case strings.Contains(value, "未繳") || strings.Contains(value, "未付") || strings.Contains(value, "待"):
+		return types.OrderUnpaid
+	case strings.Contains(value, "已繳") || strings.Contains(value, "已付") || strings.Contains(value, "完成"):
+		return types.OrderPaid
+	}
+	return types.OrderUnknown
+}
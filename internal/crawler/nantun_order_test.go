@@ -0,0 +1,59 @@
+package crawler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tian841224/crawler_sportcenter/internal/types"
+)
+
+func TestParseNantunOrders(t *testing.T) {
+	got, err := ParseNantunOrders(openFixture(t, "nantun_orders.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []types.Order{
+		{OrderID: "A1130001", CourtName: "羽球A場", Start: fixtureAt(3, 19), End: fixtureAt(3, 20), Price: 350, Status: types.OrderUnpaid,
+			PaymentDeadline: fixtureAt(2, 18)},
+		{OrderID: "A1130002", CourtName: "羽球C場", Start: fixtureAt(4, 7), End: fixtureAt(4, 8), Price: 300, Status: types.OrderPaid},
+		{OrderID: "A1130003", CourtName: "羽球E場", Start: fixtureAt(5, 20), End: fixtureAt(5, 21), Price: 350, Status: types.OrderCancelled},
+		{OrderID: "A1130004", CourtName: "羽球B場", Start: fixtureAt(6, 6), End: fixtureAt(6, 7), Price: 250, Status: types.OrderExpired,
+			PaymentDeadline: fixtureDate(6).Add(-time.Minute)},
+		{OrderID: "A1130005", CourtName: "羽球D場", Start: fixtureAt(7, 8), End: fixtureAt(7, 9), Price: 300, Status: types.OrderUnknown},
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d orders, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		g, w := got[i], want[i]
+		if g.OrderID != w.OrderID || g.CourtName != w.CourtName || !g.Start.Equal(w.Start) || !g.End.Equal(w.End) ||
+			g.Price != w.Price || g.Status != w.Status || !g.PaymentDeadline.Equal(w.PaymentDeadline) {
+			t.Errorf("order %d = %+v, want %+v", i, g, w)
+		}
+	}
+}
+
+func TestParseOrderStatus(t *testing.T) {
+	tests := []struct {
+		text string
+		want types.OrderStatus
+	}{
+		{"未繳費", types.OrderUnpaid},
+		{"待付款", types.OrderUnpaid},
+		{"已繳費", types.OrderPaid},
+		{"付款完成", types.OrderPaid},
+		{"已取消", types.OrderCancelled},
+		{"逾期未繳", types.OrderExpired},
+		{"未繳費取消", types.OrderExpired},
+		{"處理中", types.OrderUnknown},
+		{"", types.OrderUnknown},
+	}
+
+	for _, tt := range tests {
+		if got := parseOrderStatus(tt.text); got != tt.want {
+			t.Errorf("parseOrderStatus(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
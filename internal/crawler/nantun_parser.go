@@ -0,0 +1,122 @@
+package crawler
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/tian841224/crawler_sportcenter/internal/types"
+)
+
+// 南屯頁面解析，只處理 HTML，不依賴瀏覽器或網路
+
+var (
+	// 預約按鈕參數 (例如 DoSubmit2(84,'2025-05-13',6,250))
+	doSubmit2Pattern = regexp.MustCompile(`DoSubmit2\((\d+),['"](\S+)['"],(\d+),(\d+)\)`)
+	// 日期列參數 (例如 SelectDate('2025-05-20'))
+	selectDatePattern = regexp.MustCompile(`'(\d{4}-\d{2}-\d{2})'`)
+)
+
+// ParseNantunSlots 解析場地列表頁，只保留有預約按鈕的場地
+func ParseNantunSlots(r io.Reader) ([]types.Slot, error) {
+	doc, err := goquery.NewDocumentFromReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return parseNantunSlots(doc), nil
+}
+
+// ParseNantunBookableDates 解析日期列中所有可選擇的日期
+func ParseNantunBookableDates(r io.Reader) ([]time.Time, error) {
+	doc, err := goquery.NewDocumentFromReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return parseNantunBookableDates(doc), nil
+}
+
+func parseNantunSlots(doc *goquery.Document) []types.Slot {
+	slots := []types.Slot{}
+	doc.Find("div.listbackground > div.imformation1, div.listbackground > div.imformation2").Each(func(_ int, item *goquery.Selection) {
+		// 沒有 listbtn，表示不可預約
+		bookBtn := item.Find("div.courseintro div.listbtn")
+		if bookBtn.Length() == 0 {
+			return
+		}
+
+		// 取得預約參數，日期也由參數取得
+		params, ok := parseDoSubmit2(bookBtn.AttrOr("onclick", ""))
+		if !ok {
+			return
+		}
+
+		slot := types.Slot{
+			VenueID: types.VenueNantun,
+			CourtID: params.CourtID,
+			Price:   params.Price,
+			Params:  params,
+		}
+
+		// 場地名稱、價格、時間依序為前三個 listtext
+		listTexts := item.Find("div.textcss div.listtext")
+		if listTexts.Length() >= 2 {
+			slot.CourtName = strings.TrimSpace(listTexts.Eq(0).Text())
+			if price := types.ParsePrice(listTexts.Eq(1).Text()); price > 0 {
+				slot.Price = price
+			}
+		}
+		if listTexts.Length() >= 3 {
+			start, end, err := types.ParseTimeRange(params.Date, strings.TrimSpace(listTexts.Eq(2).Text()))
+			if err == nil {
+				slot.Start, slot.End = start, end
+			}
+		}
+
+		slots = append(slots, slot)
+	})
+	return slots
+}
+
+func parseNantunBookableDates(doc *goquery.Document) []time.Time {
+	var dates []time.Time
+	doc.Find(`div[onclick*="SelectDate"]`).Each(func(_ int, sel *goquery.Selection) {
+		matches := selectDatePattern.FindStringSubmatch(sel.AttrOr("onclick", ""))
+		if len(matches) < 2 {
+			return
+		}
+		if date, err := types.ParseDate(matches[1]); err == nil {
+			dates = append(dates, date)
+		}
+	})
+	return dates
+}
+
+// 解析預約按鈕的 DoSubmit2 參數
+func parseDoSubmit2(onclick string) (types.BookingParams, bool) {
+	matches := doSubmit2Pattern.FindStringSubmatch(onclick)
+	if len(matches) < 5 {
+		return types.BookingParams{}, false
+	}
+
+	courtID, err := strconv.Atoi(matches[1])
+	if err != nil {
+		return types.BookingParams{}, false
+	}
+	date, err := types.ParseDate(matches[2])
+	if err != nil {
+		return types.BookingParams{}, false
+	}
+	period, _ := strconv.Atoi(matches[3])
+	price, _ := strconv.Atoi(matches[4])
+
+	return types.BookingParams{CourtID: courtID, Date: date, Period: period, Price: price}, true
+}
+
+// 以預約參數組成 DoSubmit2 呼叫
+func doSubmit2Script(params types.BookingParams) string {
+	return fmt.Sprintf("DoSubmit2(%d,'%s',%d,%d)", params.CourtID, params.Date.Format(types.DateLayout), params.Period, params.Price)
+}
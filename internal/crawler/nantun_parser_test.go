@@ -0,0 +1,120 @@
+package crawler
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tian841224/crawler_sportcenter/internal/types"
+)
+
+func openFixture(t *testing.T, name string) *os.File {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+func fixtureDate(day int) time.Time {
+	return time.Date(2026, 11, day, 0, 0, 0, 0, time.Local)
+}
+
+func fixtureAt(day, hour int) time.Time {
+	return fixtureDate(day).Add(time.Duration(hour) * time.Hour)
+}
+
+func TestParseNantunSlots(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    []types.Slot
+	}{
+		{
+			fixture: "nantun_morning.html",
+			want: []types.Slot{
+				{VenueID: types.VenueNantun, CourtID: 84, CourtName: "羽球A場", Start: fixtureAt(3, 6), End: fixtureAt(3, 7), Price: 250,
+					Params: types.BookingParams{CourtID: 84, Date: fixtureDate(3), Period: 6, Price: 250}},
+				{VenueID: types.VenueNantun, CourtID: 86, CourtName: "羽球C場", Start: fixtureAt(3, 7), End: fixtureAt(3, 8), Price: 300,
+					Params: types.BookingParams{CourtID: 86, Date: fixtureDate(3), Period: 7, Price: 300}},
+			},
+		},
+		{
+			fixture: "nantun_afternoon.html",
+			want: []types.Slot{
+				{VenueID: types.VenueNantun, CourtID: 87, CourtName: "羽球D場", Start: fixtureAt(3, 14), End: fixtureAt(3, 15), Price: 300,
+					Params: types.BookingParams{CourtID: 87, Date: fixtureDate(3), Period: 14, Price: 300}},
+			},
+		},
+		{
+			fixture: "nantun_evening.html",
+			want: []types.Slot{
+				{VenueID: types.VenueNantun, CourtID: 84, CourtName: "羽球A場", Start: fixtureAt(3, 19), End: fixtureAt(3, 20), Price: 350,
+					Params: types.BookingParams{CourtID: 84, Date: fixtureDate(3), Period: 19, Price: 350}},
+				{VenueID: types.VenueNantun, CourtID: 88, CourtName: "羽球E場", Start: fixtureAt(3, 19), End: fixtureAt(3, 20), Price: 350,
+					Params: types.BookingParams{CourtID: 88, Date: fixtureDate(3), Period: 19, Price: 350}},
+				{VenueID: types.VenueNantun, CourtID: 89, CourtName: "羽球F場", Start: fixtureAt(3, 20), End: fixtureAt(3, 21), Price: 350,
+					Params: types.BookingParams{CourtID: 89, Date: fixtureDate(3), Period: 20, Price: 350}},
+			},
+		},
+		{fixture: "nantun_full.html", want: []types.Slot{}},
+		{fixture: "nantun_empty.html", want: []types.Slot{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			got, err := ParseNantunSlots(openFixture(t, tt.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d slots, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i := range tt.want {
+				if !slotEqual(got[i], tt.want[i]) {
+					t.Errorf("slot %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func slotEqual(a, b types.Slot) bool {
+	return a.VenueID == b.VenueID && a.CourtID == b.CourtID && a.CourtName == b.CourtName &&
+		a.Start.Equal(b.Start) && a.End.Equal(b.End) && a.Price == b.Price &&
+		a.Params.CourtID == b.Params.CourtID && a.Params.Date.Equal(b.Params.Date) &&
+		a.Params.Period == b.Params.Period && a.Params.Price == b.Params.Price
+}
+
+func TestParseNantunBookableDates(t *testing.T) {
+	week := []time.Time{fixtureDate(2), fixtureDate(3), fixtureDate(4), fixtureDate(5), fixtureDate(6), fixtureDate(7), fixtureDate(8)}
+
+	tests := []struct {
+		fixture string
+		want    []time.Time
+	}{
+		{fixture: "nantun_morning.html", want: week},
+		{fixture: "nantun_full.html", want: week},
+		{fixture: "nantun_empty.html", want: week},
+		{fixture: "nantun_orders.html", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			got, err := ParseNantunBookableDates(openFixture(t, tt.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d dates, want %d: %v", len(got), len(tt.want), got)
+			}
+			for i := range tt.want {
+				if !got[i].Equal(tt.want[i]) {
+					t.Errorf("date %d = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
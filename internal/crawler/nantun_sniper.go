@@ -0,0 +1,311 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-rod/rod"
+	"github.com/tian841224/crawler_sportcenter/internal/browser"
+	"github.com/tian841224/crawler_sportcenter/internal/clock"
+	"github.com/tian841224/crawler_sportcenter/internal/domain/booking"
+	"github.com/tian841224/crawler_sportcenter/internal/domain/user"
+	"github.com/tian841224/crawler_sportcenter/internal/types"
+	"github.com/tian841224/crawler_sportcenter/pkg/config"
+	"github.com/tian841224/crawler_sportcenter/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 搶場使用的頁面標籤
+const sniperTag = "sniper"
+
+// NantunSniper 在南屯開放預約的瞬間送出預約
+// 開放前先登入並停在時段列表，開放時直接呼叫 SelectDate 與 DoSubmit2
+type NantunSniper struct {
+	browserService *browser.BrowserService
+	nantun         *NantunSportCenterService
+	clock          clock.Clock
+	bookings       booking.Service
+	user           user.Service
+	adminAccountID string // 搶場使用設定檔帳號，預約紀錄記在管理員名下
+	credential     Credential
+	openTime       time.Duration // 開放時間，距離當天 00:00 的時間
+	prepare        time.Duration // 提前準備的時間
+	retries        int
+	retryInterval  time.Duration
+	dryRun         bool
+	timeSlotCode   types.TimeSlotCode
+	buttonIndex    []int
+	pref           types.CourtPreference
+	stopChan       chan struct{}
+}
+
+func NewNantunSniper(browserService *browser.BrowserService, nantun *NantunSportCenterService, clk clock.Clock, bookings booking.Service, user user.Service, cfg config.Config) (*NantunSniper, error) {
+	openAt, err := time.Parse("15:04:05", cfg.SniperOpenTime)
+	if err != nil {
+		return nil, fmt.Errorf("無效的開放時間 %s: %w", cfg.SniperOpenTime, err)
+	}
+	// 搶到的場地記錄在管理員名下，才能提醒付款與取消
+	if cfg.AdminAccountID == "" {
+		return nil, fmt.Errorf("啟用搶場需設定 ADMIN_TG_ID")
+	}
+
+	buttonIndex := cfg.ButtonIndex
+	if len(buttonIndex) == 0 {
+		buttonIndex = []int{0}
+	}
+
+	return &NantunSniper{
+		browserService: browserService,
+		nantun:         nantun,
+		clock:          clk,
+		bookings:       bookings,
+		user:           user,
+		adminAccountID: cfg.AdminAccountID,
+		credential:     Credential{Account: cfg.ID, Password: cfg.Password},
+		openTime:       time.Duration(openAt.Hour())*time.Hour + time.Duration(openAt.Minute())*time.Minute + time.Duration(openAt.Second())*time.Second,
+		prepare:        time.Duration(cfg.SniperPrepareSeconds) * time.Second,
+		retries:        cfg.SniperRetries,
+		retryInterval:  time.Duration(cfg.SniperRetryInterval) * time.Millisecond,
+		dryRun:         cfg.SniperDryRun,
+		timeSlotCode:   nantun.convertDayPeriodToTimeSlot(cfg.DayPeriod),
+		buttonIndex:    buttonIndex,
+		pref:           types.ParseCourtPreference(cfg.CourtPreference),
+		stopChan:       make(chan struct{}),
+	}, nil
+}
+
+// 啟動搶場，每天開放時間執行一次
+func (s *NantunSniper) Start(ctx context.Context) {
+	go func() {
+		for {
+			open := s.nextOpen(s.clock.Now())
+			logger.Log.Info("下次開放預約時間", zap.Time("open", open), zap.Bool("dryRun", s.dryRun))
+
+			if err := s.waitUntil(ctx, open.Add(-s.prepare)); err != nil {
+				return
+			}
+
+			if err := s.run(ctx, open); err != nil {
+				logger.Log.Error("搶場失敗", zap.Error(err))
+			}
+
+			// 避免同一個開放時間重複執行
+			if err := s.waitUntil(ctx, open.Add(time.Second)); err != nil {
+				return
+			}
+		}
+	}()
+}
+
+// 停止搶場
+func (s *NantunSniper) Stop() {
+	close(s.stopChan)
+}
+
+// 取得下一個開放時間，已過今天的開放時間則為明天
+func (s *NantunSniper) nextOpen(now time.Time) time.Time {
+	open := types.DateOnly(now).Add(s.openTime)
+	if !open.After(now) {
+		open = types.DateOnly(now.AddDate(0, 0, 1)).Add(s.openTime)
+	}
+	return open
+}
+
+// 依時鐘等待到指定時間，越接近時檢查越頻繁
+func (s *NantunSniper) waitUntil(ctx context.Context, t time.Time) error {
+	for {
+		remaining := t.Sub(s.clock.Now())
+		if remaining <= 0 {
+			return nil
+		}
+
+		sleep := remaining / 2
+		if remaining < 20*time.Millisecond {
+			sleep = remaining
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.stopChan:
+			return fmt.Errorf("搶場已停止")
+		case <-time.After(sleep):
+		}
+	}
+}
+
+// 準備頁面並在開放時間送出預約
+func (s *NantunSniper) run(ctx context.Context, open time.Time) error {
+	lease, err := s.browserService.Acquire(ctx, s.nantun.Nantun_Url, pageTag(types.VenueNantun, sniperTag))
+	if err != nil {
+		return err
+	}
+	defer lease.Release()
+	page := lease.Page
+
+	// 以頁面回應持續校時，分頁閒置被關閉後重新開啟時需再次監聽
+	if watcher, ok := s.clock.(clock.PageWatcher); ok && lease.New {
+		watcher.WatchPage(page)
+	}
+
+	if err := s.ensureLogin(page); err != nil {
+		return err
+	}
+
+	if err := s.prepareListing(page); err != nil {
+		return err
+	}
+
+	// 開放後會多出一天，目標為目前最後一天的隔天
+	dates, err := s.nantun.getBookableDates(page)
+	if err != nil {
+		return err
+	}
+	if len(dates) == 0 {
+		return fmt.Errorf("找不到可預約日期")
+	}
+	target := dates[len(dates)-1].AddDate(0, 0, 1)
+
+	// 開放前再校時一次
+	if syncer, ok := s.clock.(clock.Syncer); ok {
+		if err := syncer.Sync(ctx); err != nil {
+			logger.Log.Warn("校時失敗，使用目前的時間差", zap.Error(err))
+		}
+	}
+	logger.Log.Info("搶場準備完成", zap.String("target", target.Format(types.DateLayout)))
+
+	if err := s.waitUntil(ctx, open); err != nil {
+		return err
+	}
+
+	for i, buttonIndex := range s.buttonIndex {
+		// 預約成功後會返回首頁，需重新前往時段列表
+		if i > 0 {
+			if err := s.prepareListing(page); err != nil {
+				return err
+			}
+		}
+
+		if err := s.fire(ctx, page, target, buttonIndex); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 在開放時間選擇日期並預約，尚未開放時依設定重試
+func (s *NantunSniper) fire(ctx context.Context, page *rod.Page, target time.Time, buttonIndex int) error {
+	var lastErr error
+	for attempt := 1; attempt <= s.retries; attempt++ {
+		if attempt > 1 {
+			if err := s.waitUntil(ctx, s.clock.Now().Add(s.retryInterval)); err != nil {
+				return err
+			}
+		}
+
+		if err := s.nantun.selectDateByScript(page, target); err != nil {
+			lastErr = err
+			continue
+		}
+
+		slot, err := s.nantun.findFastBookButton(page, s.pref, buttonIndex)
+		if err != nil {
+			lastErr = err
+			logger.Log.Warn("尚無可預約場地", zap.Int("attempt", attempt), zap.Error(err))
+			continue
+		}
+
+		// 列表仍是舊日期，代表尚未開放
+		if !slot.Params.Date.Equal(target) {
+			lastErr = fmt.Errorf("列表日期 %s 尚未切換為 %s", slot.Params.Date.Format(types.DateLayout), target.Format(types.DateLayout))
+			logger.Log.Warn("尚未開放", zap.Int("attempt", attempt), zap.Error(lastErr))
+			continue
+		}
+
+		if s.dryRun {
+			logger.Log.Info("試跑模式，不送出預約", zap.Stringer("params", slot.Params))
+			return nil
+		}
+
+		if err := s.nantun.submitFastBooking(page, slot.Params); err != nil {
+			lastErr = err
+			logger.Log.Warn("送出預約失敗", zap.Int("attempt", attempt), zap.Error(err))
+			continue
+		}
+
+		logger.Log.Info("搶場成功", zap.Stringer("params", slot.Params))
+		s.recordBooking(ctx, slot)
+		return nil
+	}
+	return fmt.Errorf("重試 %d 次仍未成功: %w", s.retries, lastErr)
+}
+
+// 將搶到的場地記錄在管理員名下，管理員尚未使用過 Bot 時先建立使用者
+func (s *NantunSniper) recordBooking(ctx context.Context, slot types.Slot) {
+	admin, err := s.user.GetByAccountID(ctx, s.adminAccountID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		admin = &user.User{AccountID: s.adminAccountID, Status: true}
+		err = s.user.Create(ctx, admin)
+	}
+	if err != nil {
+		logger.Log.Error("get admin user", zap.Error(err))
+		return
+	}
+	if _, err := s.bookings.Record(ctx, admin.ID, slot, booking.SourceSniper); err != nil {
+		logger.Log.Error("record booking", zap.Error(err))
+	}
+}
+
+// 登入頁仍有帳號欄位時才登入，避免重複登入
+func (s *NantunSniper) ensureLogin(page *rod.Page) error {
+	if err := page.Navigate(s.nantun.Nantun_Url); err != nil {
+		return err
+	}
+	if err := waitStable(page); err != nil {
+		return err
+	}
+
+	has, _, err := page.Has("#txt_Account")
+	if err != nil {
+		return err
+	}
+	if !has {
+		return nil
+	}
+	return s.nantun.login(page, s.credential)
+}
+
+// 從首頁前往時段列表
+func (s *NantunSniper) prepareListing(page *rod.Page) error {
+	if _, err := page.Eval(`() => { window.location = '/BPHome/BPHome'; }`); err != nil {
+		return err
+	}
+	if err := waitStable(page); err != nil {
+		return err
+	}
+
+	if err := s.nantun.clickAgreeButton(page); err != nil {
+		return err
+	}
+
+	if err := s.nantun.selectLocationBooking(page); err != nil {
+		return err
+	}
+
+	if err := s.nantun.selectBadminton(page); err != nil {
+		return err
+	}
+
+	if err := s.nantun.setCheckboxAndProceed(page); err != nil {
+		return err
+	}
+
+	if err := s.nantun.proceedToBooking(page); err != nil {
+		return err
+	}
+
+	return s.nantun.selectTimeSlot(page, s.timeSlotCode)
+}
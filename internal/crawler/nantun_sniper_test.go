@@ -0,0 +1,64 @@
+package crawler
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tian841224/crawler_sportcenter/internal/domain/booking"
+	"github.com/tian841224/crawler_sportcenter/internal/domain/user"
+	"github.com/tian841224/crawler_sportcenter/internal/types"
+	"github.com/tian841224/crawler_sportcenter/pkg/config"
+	"gorm.io/gorm"
+)
+
+type fakeSniperUsers struct {
+	user.Service
+	created []*user.User
+}
+
+func (s *fakeSniperUsers) GetByAccountID(ctx context.Context, accountID string) (*user.User, error) {
+	for _, u := range s.created {
+		if u.AccountID == accountID {
+			return u, nil
+		}
+	}
+	return &user.User{}, gorm.ErrRecordNotFound
+}
+
+func (s *fakeSniperUsers) Create(ctx context.Context, u *user.User) error {
+	u.ID = uint(len(s.created) + 1)
+	s.created = append(s.created, u)
+	return nil
+}
+
+type fakeSniperBookings struct {
+	booking.Service
+	owners []uint
+}
+
+func (s *fakeSniperBookings) Record(ctx context.Context, userID uint, slot types.Slot, source booking.Source) (*booking.Booking, error) {
+	s.owners = append(s.owners, userID)
+	return &booking.Booking{}, nil
+}
+
+func TestNewNantunSniperRequiresAdmin(t *testing.T) {
+	_, err := NewNantunSniper(nil, nil, nil, nil, nil, config.Config{SniperOpenTime: "13:00:00"})
+	if err == nil {
+		t.Fatal("sniper without ADMIN_TG_ID must be rejected")
+	}
+}
+
+func TestSniperRecordsBookingForNewAdmin(t *testing.T) {
+	users := &fakeSniperUsers{}
+	bookings := &fakeSniperBookings{}
+	sniper := &NantunSniper{user: users, bookings: bookings, adminAccountID: "100"}
+
+	sniper.recordBooking(context.Background(), types.Slot{CourtName: "羽球A場"})
+
+	if len(users.created) != 1 || users.created[0].AccountID != "100" {
+		t.Fatalf("created = %+v, want the admin user", users.created)
+	}
+	if len(bookings.owners) != 1 || bookings.owners[0] != users.created[0].ID {
+		t.Errorf("booking owners = %v, want admin %d", bookings.owners, users.created[0].ID)
+	}
+}
@@ -2,7 +2,6 @@ package crawler
 
 import (
 	"fmt"
-	"regexp"
 	"strings"
 	"time"
 
@@ -10,7 +9,6 @@ import (
 	"github.com/go-rod/rod/lib/proto"
 	"github.com/tian841224/crawler_sportcenter/internal/browser"
 	"github.com/tian841224/crawler_sportcenter/internal/types"
-	"github.com/tian841224/crawler_sportcenter/pkg/config"
 	"github.com/tian841224/crawler_sportcenter/pkg/logger"
 )
 
@@ -20,12 +18,12 @@ type NantunSportCenterInterface interface {
 var _ NantunSportCenterInterface = (*NantunSportCenterService)(nil)
 
 type NantunSportCenterService struct {
-	browserService browser.BrowserService
+	browserService *browser.BrowserService
 	Nantun_Url     string // 南屯運動中心網址
 	paymentURL     string // 繳費網址
 }
 
-func NewNantunSportCenterService(browserService browser.BrowserService) NantunSportCenterService {
+func NewNantunSportCenterService(browserService *browser.BrowserService) NantunSportCenterService {
 	return NantunSportCenterService{
 		browserService: browserService,
 		Nantun_Url:     "https://nd01.xuanen.com.tw/BPMember/BPMemberLogin",
@@ -33,159 +31,65 @@ func NewNantunSportCenterService(browserService browser.BrowserService) NantunSp
 	}
 }
 
-// 快速預定場地
-func (s *NantunSportCenterService) QuickCrawlerNantun(cfg config.Config) error {
-	page, err := s.browserService.GetPage(s.Nantun_Url, "")
+// 執行登入
+func (s *NantunSportCenterService) login(page *rod.Page, cred Credential) error {
+	account, err := findElement(page, "#txt_Account")
 	if err != nil {
+		logger.Log.Error("無法找到登入表單: " + err.Error())
 		return err
 	}
-
-	if err := s.login(page, cfg); err != nil {
+	if err := account.Input(cred.Account); err != nil {
+		logger.Log.Error("無法輸入身分證字號: " + err.Error())
 		return err
 	}
+	logger.Log.Info("填寫身分證字號")
 
-	for _, buttonIndex := range cfg.ButtonIndex {
-
-		if err := s.clickAgreeButton(page); err != nil {
-			return err
-		}
-
-		if err := s.selectLocationBooking(page); err != nil {
-			return err
-		}
-
-		if err := s.selectBadminton(page); err != nil {
-			return err
-		}
-
-		if err := s.setCheckboxAndProceed(page); err != nil {
-			return err
-		}
-
-		if err := s.proceedToBooking(page); err != nil {
-			return err
-		}
-
-		if err := s.selectTimeSlot(page, s.convertDayPeriodToTimeSlot(cfg.DayPeriod)); err != nil {
-			return err
-		}
-
-		if err := s.fastSelectLastDate(page); err != nil {
-			return err
-		}
-
-		if err := s.fastBookCourt(page, buttonIndex); err != nil {
-			return err
-		}
+	password, err := findElement(page, "#txt_Pass")
+	if err == nil {
+		err = password.Input(cred.Password)
 	}
-	s.browserService.Close()
-	return nil
-}
-
-// 爬蟲南屯運動中心
-func (s *NantunSportCenterService) CrawlerNantun(cfg config.Config, tag string) error {
-
-	page, err := s.browserService.GetPage(s.Nantun_Url, tag)
 	if err != nil {
+		logger.Log.Error("無法輸入密碼: " + err.Error())
 		return err
 	}
+	logger.Log.Info("填寫密碼")
 
-	if err := s.login(page, cfg); err != nil {
-		return err
-	}
-
-	bookCount := 0
-	for _, timeSlotCode := range cfg.TimeSlotCodes {
-		if err := s.clickAgreeButton(page); err != nil {
-			return err
-		}
-
-		if err := s.selectLocationBooking(page); err != nil {
-			continue
-		}
-
-		if err := s.selectBadminton(page); err != nil {
-			continue
-		}
-
-		if err := s.setCheckboxAndProceed(page); err != nil {
-			return err
-		}
-
-		if err := s.proceedToBooking(page); err != nil {
-			return err
-		}
-
-		if err := s.selectDate(page, cfg.ChooseWeekday); err != nil {
-			return err
-		}
-
-		if err := s.selectTimeSlot(page, timeSlotCode); err != nil {
-			continue
-		}
-
-		cleanSlots, err := s.getAllAvailableTimeSlots(page)
-		if err != nil {
-			continue
-		}
-
-		targetSlot := s.findAvailableCourtsByTimeSlot(cleanSlots, timeSlotCode)
-
-		if err := s.bookCourt(page, targetSlot); err != nil {
-			logger.Log.Error(fmt.Sprintf("預約時段 %v 失敗: %s", types.TimeSlotMap[timeSlotCode], err))
-			continue
-		}
-
-		bookCount++
+	button, err := findElement(page, ".CssLoginBtn")
+	if err == nil {
+		err = button.Click(proto.InputMouseButtonLeft, 1)
 	}
-
-	if err := s.clickAgreeButton(page); err != nil {
+	if err != nil {
+		logger.Log.Error("無法點擊登入按鈕: " + err.Error())
 		return err
 	}
+	logger.Log.Info("點擊登入按鈕")
 
-	if bookCount > 0 {
-		if err := s.navigateToPayment(page); err != nil {
-			return err
-		}
-	}
-
-	s.browserService.Close()
-	return nil
+	return waitStable(page)
 }
 
-// 執行登入
-func (s *NantunSportCenterService) login(page *rod.Page, cfg config.Config) error {
-	if err := page.MustElement("#txt_Account").Input(cfg.ID); err != nil {
-		logger.Log.Error("無法輸入身分證字號: " + err.Error())
-		return err
-	}
-	logger.Log.Info("填寫身分證字號")
-
-	if err := page.MustElement("#txt_Pass").Input(cfg.Password); err != nil {
-		logger.Log.Error("無法輸入密碼: " + err.Error())
-		return err
+// 是否已被登出：網站登入逾時後會導回登入頁，或頁面上出現登入表單
+func (s *NantunSportCenterService) isLoggedOut(page *rod.Page) bool {
+	info, err := page.Info()
+	if err == nil && strings.Contains(info.URL, nantunLoginPath) {
+		return true
 	}
-	logger.Log.Info("填寫密碼")
 
-	if err := page.MustElement(".CssLoginBtn").Click(proto.InputMouseButtonLeft, 1); err != nil {
-		logger.Log.Error("無法點擊登入按鈕: " + err.Error())
-		return err
-	}
-	logger.Log.Info("點擊登入按鈕")
-
-	page.MustWaitStable()
-	return nil
+	has, _, err := page.Has("#txt_Account")
+	return err == nil && has
 }
 
 // 點擊預防詐騙確認按鈕
 func (s *NantunSportCenterService) clickAgreeButton(page *rod.Page) error {
-	if err := page.MustElement("#Msg_Agree").Click(proto.InputMouseButtonLeft, 1); err != nil {
+	button, err := findElement(page, "#Msg_Agree")
+	if err == nil {
+		err = button.Click(proto.InputMouseButtonLeft, 1)
+	}
+	if err != nil {
 		logger.Log.Error("無法點擊確認按鈕: " + err.Error())
 		return err
 	}
 	logger.Log.Info("點擊確認按鈕")
-	page.MustWaitStable()
-	return nil
+	return waitStable(page)
 }
 
 // 點選場地預約
@@ -202,19 +106,21 @@ func (s *NantunSportCenterService) selectLocationBooking(page *rod.Page) error {
 		return err
 	}
 	logger.Log.Info("觸發場地預約按鈕的 onclick 事件")
-	page.MustWaitStable()
-	return nil
+	return waitStable(page)
 }
 
 // 點擊羽球按鈕
 func (s *NantunSportCenterService) selectBadminton(page *rod.Page) error {
-	if err := page.MustElement(".CssAdImg[data-slick-index='0']").Click(proto.InputMouseButtonLeft, 1); err != nil {
+	button, err := findElement(page, ".CssAdImg[data-slick-index='0']")
+	if err == nil {
+		err = button.Click(proto.InputMouseButtonLeft, 1)
+	}
+	if err != nil {
 		logger.Log.Error("無法點擊羽球按鈕: " + err.Error())
 		return err
 	}
 	logger.Log.Info("點擊羽球按鈕")
-	page.MustWaitStable()
-	return nil
+	return waitStable(page)
 }
 
 // 設定勾選框狀態
@@ -232,7 +138,9 @@ func (s *NantunSportCenterService) setCheckboxAndProceed(page *rod.Page) error {
 		return err
 	}
 
-	page.MustWaitStable()
+	if err := waitStable(page); err != nil {
+		return err
+	}
 	logger.Log.Info("設定勾選框狀態和觸發點擊事件")
 	return nil
 }
@@ -247,90 +155,65 @@ func (s *NantunSportCenterService) proceedToBooking(page *rod.Page) error {
 		return err
 	}
 
-	page.MustWaitStable()
+	if err := waitStable(page); err != nil {
+		return err
+	}
 	logger.Log.Info("觸發預約場地按鈕的 onclick 事件")
 	return nil
 }
 
-// 選擇日期
-func (s *NantunSportCenterService) selectDate(page *rod.Page, targetWeekday string) error {
-	weekdays := []string{}
-
-	firstDatebox := page.MustElement(".datebox")
-	dateElements := firstDatebox.MustElements("div")
-
-	for _, element := range dateElements {
-		weekday := element.MustText()
-		weekdays = append(weekdays, weekday)
+// 選擇日期，日期列中沒有該日期時表示尚未開放預約
+func (s *NantunSportCenterService) selectDate(page *rod.Page, date time.Time) error {
+	// 登入逾時時頁面會被導回登入頁，找不到日期列時回傳錯誤而非一直等待
+	if _, err := page.Timeout(10 * time.Second).Element(".datebox"); err != nil {
+		logger.Log.Error("找不到日期框: " + err.Error())
+		return fmt.Errorf("找不到日期框: %w", err)
 	}
 
-	logger.Log.Info("取得的星期資訊:")
-	for i, day := range weekdays {
-		logger.Log.Info(fmt.Sprintf("%d: %s", i+1, day))
-	}
-
-	secondDatebox := page.MustElements("div.datebox")
-	if len(secondDatebox) < 2 {
-		logger.Log.Error("找不到第二個日期框")
-		return fmt.Errorf("找不到第二個日期框")
-	}
-
-	dateButtons := secondDatebox[1].MustElements("div")
-	if len(dateButtons) < len(weekdays) {
-		logger.Log.Error(fmt.Sprintf("日期按鈕數量不足，只有 %d 個按鈕", len(dateButtons)))
-		return fmt.Errorf("日期按鈕數量不足")
-	}
-
-	weekdayIndex := -1
-	for i, day := range weekdays {
-		if day == targetWeekday {
-			weekdayIndex = i
-			break
-		}
+	dateText := date.Format(types.DateLayout)
+	has, dateButton, err := page.Has(fmt.Sprintf(`div[onclick*="SelectDate('%s')"]`, dateText))
+	if err != nil {
+		return err
 	}
-
-	if weekdayIndex == -1 {
-		logger.Log.Error(fmt.Sprintf("找不到星期%s", targetWeekday))
-		return fmt.Errorf("找不到指定的星期")
+	if !has {
+		logger.Log.Error(fmt.Sprintf("找不到日期 %s", dateText))
+		return fmt.Errorf("日期 %s 尚未開放預約", dateText)
 	}
 
-	logger.Log.Info(fmt.Sprintf("找到星期%s，索引為 %d", targetWeekday, weekdayIndex))
-
-	dateToClick := dateButtons[weekdayIndex]
-	dateText := dateToClick.MustText()
-	logger.Log.Info(fmt.Sprintf("選擇的日期是: %s", dateText))
-
-	if err := dateToClick.Click(proto.InputMouseButtonLeft, 1); err != nil {
+	if err := dateButton.Click(proto.InputMouseButtonLeft, 1); err != nil {
 		logger.Log.Error(fmt.Sprintf("點選日期失敗: %s", err))
 		return err
 	}
 
-	page.MustWaitStable()
-	logger.Log.Info("日期點選成功")
+	if err := waitStable(page); err != nil {
+		return err
+	}
+	logger.Log.Info(fmt.Sprintf("選擇的日期是: %s", dateText))
 	return nil
 }
 
-// 前往繳費頁面
-func (s *NantunSportCenterService) navigateToPayment(page *rod.Page) error {
-	if err := page.Navigate(s.paymentURL); err != nil {
-		return err
+// 取得日期列中所有可選擇的日期
+func (s *NantunSportCenterService) getBookableDates(page *rod.Page) ([]time.Time, error) {
+	html, err := page.HTML()
+	if err != nil {
+		logger.Log.Error(fmt.Sprintf("讀取日期列失敗: %s", err))
+		return nil, err
 	}
-	page.MustWaitStable()
-	return nil
+
+	dates, err := ParseNantunBookableDates(strings.NewReader(html))
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Log.Info(fmt.Sprintf("可預約日期共 %d 天", len(dates)))
+	return dates, nil
 }
 
 // SelectTimeSlot 選擇時段（1=上午，2=下午，3=晚上）
 func (s *NantunSportCenterService) selectTimeSlot(page *rod.Page, timeSlotCode types.TimeSlotCode) error {
 
 	// 判斷時段，1-12 為上午，13-18 為下午，19-24 為晚上
-	var timeSlot int
-	if timeSlotCode <= types.TimeSlot_11_12 {
-		timeSlot = 1
-	} else if timeSlotCode <= types.TimeSlot_17_18 {
-		timeSlot = 2
-	} else {
-		timeSlot = 3
-	}
+	timeSlot := timeSlotCode.DayPeriod()
 
 	// 檢查時段參數是否有效
 	if timeSlot < 1 || timeSlot > 3 {
@@ -359,7 +242,9 @@ func (s *NantunSportCenterService) selectTimeSlot(page *rod.Page, timeSlotCode t
 	}
 
 	// 等待頁面載入完成
-	page.MustWaitStable()
+	if err := waitStable(page); err != nil {
+		return err
+	}
 
 	// 記錄選擇的時段
 	timeSlotNames := map[int]string{1: "上午", 2: "下午", 3: "晚上"}
@@ -368,126 +253,47 @@ func (s *NantunSportCenterService) selectTimeSlot(page *rod.Page, timeSlotCode t
 }
 
 // GetAvailableTimeSlots 取得所有可預約的時段資訊
-func (s *NantunSportCenterService) getAllAvailableTimeSlots(page *rod.Page) ([]types.CleanTimeSlot, error) {
-	// 設定超時和等待參數
-	timeout := 10 * time.Second
-	page.Timeout(timeout)
-
-	// 等待頁面加載完成
-	err := page.WaitStable(2 * time.Second)
-	if err != nil {
-		logger.Log.Error(fmt.Sprintf("等待頁面穩定失敗: %s", err))
+func (s *NantunSportCenterService) getAllAvailableTimeSlots(page *rod.Page) ([]types.Slot, error) {
+	// 等待頁面加載完成，頁面沒有回應時逾時回傳錯誤
+	if err := waitStable(page); err != nil {
+		logger.Log.Error(err.Error())
 		return nil, err
 	}
 
-	// 搜尋所有時段元素
-	listItems, err := page.Elements("div.listbackground > div.imformation1, div.listbackground > div.imformation2")
+	// 取得頁面 HTML 後交由解析器處理
+	html, err := page.HTML()
 	if err != nil {
-		logger.Log.Error(fmt.Sprintf("找不到時段元素: %s", err))
+		logger.Log.Error(fmt.Sprintf("讀取頁面內容失敗: %s", err))
 		return nil, err
 	}
 
-	logger.Log.Info(fmt.Sprintf("找到 %d 個時段元素", len(listItems)))
-
-	var slots []types.TimeSlot
-
-	// 處理每個時段元素
-	for _, item := range listItems {
-		// 確保元素有效
-		if item == nil {
-			continue
-		}
-
-		// 檢查是否有 listbtn（可預約按鈕）
-		var bookBtn *rod.Element
-		bookBtn, err = item.Element("div.courseintro div.listbtn")
-		if err != nil || bookBtn == nil {
-			// 沒有 listbtn，表示不可預約
-			continue
-		}
-
-		slot := types.TimeSlot{}
-
-		// 取得場地名稱和價格
-		listTexts, err := item.Elements("div.textcss div.listtext")
-		if err == nil && len(listTexts) >= 2 {
-			// 場地名稱（第一個 listtext）
-			courtName, err := listTexts[0].Text()
-			if err == nil {
-				slot.CourtName = strings.TrimSpace(courtName)
-			}
-
-			// 價格（第二個 listtext）
-			price, err := listTexts[1].Text()
-			if err == nil {
-				slot.Price = strings.TrimSpace(price)
-			}
-
-			// 時間（第三個 listtext）
-			if len(listTexts) >= 3 {
-				time, err := listTexts[2].Text()
-				if err == nil {
-					slot.Time = strings.TrimSpace(time)
-				}
-			}
-		}
-
-		// 取得預約參數
-		onclick, err := bookBtn.Attribute("onclick")
-		if err == nil && onclick != nil && *onclick != "" {
-			// 解析預約參數 (例如 DoSubmit2(84,'2025-05-13',6,250))
-			re := regexp.MustCompile(`DoSubmit2\((\d+),['"](\S+)['"],(\d+),(\d+)\)`)
-			matches := re.FindStringSubmatch(*onclick)
-
-			if len(matches) >= 5 {
-				slot.Button = *onclick
-				slot.Date = matches[2]
-				slot.Period = matches[3]
-			}
-		}
-
-		slots = append(slots, slot)
-	}
-
-	cleanSlots := make([]types.CleanTimeSlot, 0, len(slots))
-	for _, slot := range slots {
-		cleanSlots = append(cleanSlots, types.CleanTimeSlot{
-			CourtName: slot.CourtName,
-			Price:     slot.Price,
-			Time:      slot.Time,
-			BookingID: slot.BookingID,
-			Date:      slot.Date,
-			Period:    slot.Period,
-			Fee:       slot.Fee,
-			Button:    slot.Button,
-		})
+	cleanSlots, err := ParseNantunSlots(strings.NewReader(html))
+	if err != nil {
+		logger.Log.Error(fmt.Sprintf("解析時段失敗: %s", err))
+		return nil, err
 	}
 	logger.Log.Info(fmt.Sprintf("找到 %d 個可預約時段", len(cleanSlots)))
 	return cleanSlots, nil
 }
 
 // 根據時段代碼查找可用場地
-func (s *NantunSportCenterService) findAvailableCourtsByTimeSlot(slots []types.CleanTimeSlot, code types.TimeSlotCode) []types.CleanTimeSlot {
-	targetTime := types.TimeSlotMap[code]
-	var availableCourts []types.CleanTimeSlot
+func (s *NantunSportCenterService) findAvailableCourtsByTimeSlot(slots []types.Slot, code types.TimeSlotCode) []types.Slot {
+	var availableCourts []types.Slot
 
 	for _, slot := range slots {
-		if slot.Time == targetTime {
+		if slot.Matches(code) {
 			availableCourts = append(availableCourts, slot)
 		}
 	}
 
-	logger.Log.Info(fmt.Sprintf("找到 %d 個 %v 點可預約時段", len(availableCourts), targetTime))
+	logger.Log.Info(fmt.Sprintf("找到 %d 個 %v 可預約時段", len(availableCourts), code))
 	return availableCourts
 }
 
-// 預約指定場地
-func (s *NantunSportCenterService) bookCourt(page *rod.Page, targetSlot []types.CleanTimeSlot) error {
-	for _, slot := range targetSlot {
-		// 從 Button 字符串中提取參數
-		re := regexp.MustCompile(`DoSubmit2\((\d+),['"](\S+)['"],(\d+),(\d+)\)`)
-		matches := re.FindStringSubmatch(slot.Button)
-		if len(matches) < 5 {
+// 預約指定場地，依場地偏好排序後逐一嘗試
+func (s *NantunSportCenterService) bookCourt(page *rod.Page, targetSlot []types.Slot, pref types.CourtPreference) (*types.Slot, error) {
+	for _, slot := range pref.Apply(targetSlot) {
+		if slot.Params.Date.IsZero() {
 			logger.Log.Error("無法解析預約參數")
 			continue
 		}
@@ -501,7 +307,7 @@ func (s *NantunSportCenterService) bookCourt(page *rod.Page, targetSlot []types.
                 console.error(e);
                 return false;
             }
-        }`, slot.Button)
+        }`, doSubmit2Script(slot.Params))
 
 		// 執行 JavaScript
 		result, err := page.Eval(script)
@@ -516,12 +322,17 @@ func (s *NantunSportCenterService) bookCourt(page *rod.Page, targetSlot []types.
 			continue
 		}
 
-		// 等待頁面跳轉或更新
-		page.MustWaitStable()
+		// 等待頁面跳轉或更新，頁面沒有回應時狀態不明，不再嘗試其他場地
+		if err := waitStable(page); err != nil {
+			return nil, err
+		}
 
 		// 檢查是否跳轉到預約確認頁面
-		currentURL := page.MustInfo().URL
-		if strings.Contains(currentURL, "tFlag=2") {
+		info, err := page.Info()
+		if err != nil {
+			return nil, err
+		}
+		if strings.Contains(info.URL, "tFlag=2") {
 
 			// 點擊確認按鈕
 			confirmScript := fmt.Sprintf(`() => {
@@ -532,7 +343,7 @@ func (s *NantunSportCenterService) bookCourt(page *rod.Page, targetSlot []types.
                     console.error(e);
                     return false;
                 }
-            }`, matches[2])
+            }`, slot.Params.Date.Format(types.DateLayout))
 			logger.Log.Info(fmt.Sprintf("執行確認腳本: %s", confirmScript))
 
 			// 執行確認按鈕點擊
@@ -549,8 +360,10 @@ func (s *NantunSportCenterService) bookCourt(page *rod.Page, targetSlot []types.
 			}
 
 			// 等待最終確認頁面載入
-			page.MustWaitStable()
-			logger.Log.Info(fmt.Sprintf("成功預約場地：%s，時間：%s", slot.CourtName, slot.Time))
+			if err := waitStable(page); err != nil {
+				return nil, err
+			}
+			logger.Log.Info(fmt.Sprintf("成功預約場地：%s，時間：%s", slot.CourtName, slot.TimeRange()))
 
 			// 點擊首頁按鈕返回
 			script := `() => {
@@ -567,140 +380,128 @@ func (s *NantunSportCenterService) bookCourt(page *rod.Page, targetSlot []types.
 			result, err := page.Eval(script)
 			if err != nil {
 				logger.Log.Error(fmt.Sprintf("執行返回首頁腳本失敗: %s", err))
-				return err
+				return nil, err
 			}
 
 			// 檢查是否成功執行
 			if !result.Value.Bool() {
 				logger.Log.Error("返回首頁失敗")
-				return fmt.Errorf("返回首頁失敗")
+				return nil, fmt.Errorf("返回首頁失敗")
 			}
 
-			// 等待頁面載入完成
-			page.MustWaitStable()
-			logger.Log.Info("成功返回首頁")
+			// 等待頁面載入完成，預約已成功，返回首頁失敗只記錄
+			if err := waitStable(page); err != nil {
+				logger.Log.Warn("返回首頁後等待頁面失敗: " + err.Error())
+			} else {
+				logger.Log.Info("成功返回首頁")
+			}
 
-			return nil // 完成預約流程後返回
+			return &slot, nil // 完成預約流程後返回
 		}
 	}
 
-	return fmt.Errorf("所有場地預約嘗試均失敗")
+	return nil, fmt.Errorf("所有場地預約嘗試均失敗")
 }
 
-// 快速點選最新日期
-func (s *NantunSportCenterService) fastSelectLastDate(page *rod.Page) error {
-
-	secondDatebox := page.MustElements("div.datebox")
-	if len(secondDatebox) < 2 {
-		logger.Log.Error("找不到第二個日期框")
-		return fmt.Errorf("找不到第二個日期框")
-	}
-
-	dateButtons := secondDatebox[1].MustElements("div")
-	dateToClick := dateButtons[6]
+// 直接呼叫 SelectDate 選擇日期，日期按鈕尚未出現時也可使用
+func (s *NantunSportCenterService) selectDateByScript(page *rod.Page, date time.Time) error {
+	script := fmt.Sprintf(`() => {
+		try {
+			SelectDate('%s');
+			return true;
+		} catch (e) {
+			console.error(e);
+			return false;
+		}
+	}`, date.Format(types.DateLayout))
 
-	if err := dateToClick.Click(proto.InputMouseButtonLeft, 1); err != nil {
-		logger.Log.Error(fmt.Sprintf("點選日期失敗: %s", err))
+	result, err := page.Eval(script)
+	if err != nil {
+		logger.Log.Error(fmt.Sprintf("執行日期選擇腳本失敗: %s", err))
 		return err
 	}
-
-	// 使用 JavaScript 查找並點擊最後一個可用日期
-	script := `() => {
-	    // 先嘗試找帶有 selectweek class 的日期按鈕
-	    let dateButton = document.querySelector('div.selectweek[onclick*="SelectDate"]');
-	    if (!dateButton) {
-	        // 如果沒有找到，則查找普通的日期按鈕
-	        const allDateButtons = Array.from(document.querySelectorAll('div[onclick*="SelectDate"]'));
-	        if (allDateButtons.length > 0) {
-	            dateButton = allDateButtons[allDateButtons.length - 1];
-	        }
-	    }
-
-	    if (dateButton) {
-	        // 從 onclick 屬性中提取日期
-	        const onclickAttr = dateButton.getAttribute('onclick');
-	        const dateMatch = onclickAttr.match(/'(\d{4}-\d{2}-\d{2})'/);
-	        if (dateMatch) {
-	            SelectDate(dateMatch[1]);
-	            return true;
-	        }
-	    }
-	    return false;
-	}`
-
-	for {
-		currentTime := time.Now()
-		if currentTime.Hour() == 12 { // 12 點後停止點擊
-			break
-		}
-		if currentTime.Hour() == 12 && currentTime.Minute() == 59 { // 12:59分後開始點
-			// 執行腳本
-			result, err := page.Eval(script)
-			if err != nil {
-				logger.Log.Error(fmt.Sprintf("執行日期選擇腳本失敗: %s", err))
-				return err
-			}
-
-			if !result.Value.Bool() {
-				logger.Log.Error("找不到可點擊的日期按鈕")
-				return fmt.Errorf("找不到可點擊的日期按鈕")
-			}
-
-			// 等待頁面穩定
-			page.MustWaitStable()
-		}
+	if !result.Value.Bool() {
+		return fmt.Errorf("選擇日期 %s 失敗", date.Format(types.DateLayout))
 	}
 
-	logger.Log.Info("日期點選成功")
-	return nil
+	return waitStable(page)
 }
 
-// 快速預約場地
-// 預約指定場地
-func (s *NantunSportCenterService) fastBookCourt(page *rod.Page, buttonIndex int) error {
-	// 使用 JavaScript 找到所有預約按鈕
+// 找出要預約的按鈕，回傳對應的場地
+func (s *NantunSportCenterService) findFastBookButton(page *rod.Page, pref types.CourtPreference, buttonIndex int) (types.Slot, error) {
+	// 使用 JavaScript 找到所有預約按鈕與場地名稱
 	script := `() => {
         const buttons = document.querySelectorAll('.listbtn[onclick*="DoSubmit2"]');
-        return Array.from(buttons).map(btn => btn.getAttribute('onclick'));
+        return Array.from(buttons).map(btn => {
+            const item = btn.closest('div.imformation1, div.imformation2');
+            const texts = item ? item.querySelectorAll('div.textcss div.listtext') : [];
+            return {
+                courtName: texts.length > 0 ? texts[0].innerText.trim() : '',
+                time: texts.length > 2 ? texts[2].innerText.trim() : '',
+                button: btn.getAttribute('onclick'),
+            };
+        });
     }`
 
 	// 執行腳本獲取所有按鈕的 onclick 屬性
 	result, err := page.Eval(script)
 	if err != nil {
 		logger.Log.Error(fmt.Sprintf("獲取預約按鈕失敗: %s", err))
-		return err
+		return types.Slot{}, err
 	}
 
-	// 將結果轉換為字符串切片
-	var buttons []string
+	var buttons []struct {
+		CourtName string `json:"courtName"`
+		Time      string `json:"time"`
+		Button    string `json:"button"`
+	}
 	if err := result.Value.Unmarshal(&buttons); err != nil {
 		logger.Log.Error(fmt.Sprintf("解析按鈕資訊失敗: %s", err))
-		return err
+		return types.Slot{}, err
 	}
 
-	// 您可以指定要點擊第幾個按鈕（例如第一個按鈕索引為 0）
-	if buttonIndex >= len(buttons) {
-		return fmt.Errorf("指定的按鈕索引 %d 超出範圍，總共有 %d 個按鈕", buttonIndex, len(buttons))
+	// 將按鈕轉換為場地列表，無法解析參數的按鈕略過
+	var slots []types.Slot
+	for _, button := range buttons {
+		params, ok := parseDoSubmit2(button.Button)
+		if !ok {
+			continue
+		}
+		slot := types.Slot{VenueID: types.VenueNantun, CourtID: params.CourtID, CourtName: button.CourtName, Price: params.Price, Params: params}
+		if start, end, err := types.ParseTimeRange(params.Date, button.Time); err == nil {
+			slot.Start, slot.End = start, end
+		}
+		slots = append(slots, slot)
 	}
 
-	// 從選定按鈕的 onclick 屬性中提取參數
-	selectedButton := buttons[buttonIndex]
-	re := regexp.MustCompile(`DoSubmit2\((\d+),['"](\S+)['"],(\d+),(\d+)\)`)
-	matches := re.FindStringSubmatch(selectedButton)
-	if len(matches) < 5 {
-		return fmt.Errorf("無法解析選定按鈕的預約參數")
+	if pref.IsEmpty() {
+		// 您可以指定要點擊第幾個按鈕（例如第一個按鈕索引為 0）
+		if buttonIndex >= len(slots) {
+			return types.Slot{}, fmt.Errorf("指定的按鈕索引 %d 超出範圍，總共有 %d 個按鈕", buttonIndex, len(slots))
+		}
+		return slots[buttonIndex], nil
 	}
 
+	candidates := pref.Apply(slots)
+	if len(candidates) == 0 {
+		return types.Slot{}, fmt.Errorf("沒有符合場地偏好的場地，總共有 %d 個按鈕", len(slots))
+	}
+	logger.Log.Info(fmt.Sprintf("依場地偏好選擇 %s", candidates[0].CourtName))
+	return candidates[0], nil
+}
+
+// 以 DoSubmit2 參數送出預約並確認
+func (s *NantunSportCenterService) submitFastBooking(page *rod.Page, params types.BookingParams) error {
 	// 執行預約
 	bookScript := fmt.Sprintf(`() => {
         try {
-            DoSubmit2(%s,'%s',%s,%s);
+            %s;
             return true;
         } catch (e) {
             console.error(e);
             return false;
         }
-    }`, matches[1], matches[2], matches[3], matches[4])
+    }`, doSubmit2Script(params))
 
 	// 執行預約腳本
 	bookResult, err := page.Eval(bookScript)
@@ -714,11 +515,16 @@ func (s *NantunSportCenterService) fastBookCourt(page *rod.Page, buttonIndex int
 	}
 
 	// 等待頁面跳轉或更新
-	page.MustWaitStable()
+	if err := waitStable(page); err != nil {
+		return err
+	}
 
 	// 檢查是否跳轉到預約確認頁面
-	currentURL := page.MustInfo().URL
-	if strings.Contains(currentURL, "tFlag=2") {
+	info, err := page.Info()
+	if err != nil {
+		return err
+	}
+	if strings.Contains(info.URL, "tFlag=2") {
 		// 點擊確認按鈕
 		confirmScript := fmt.Sprintf(`() => {
             try {
@@ -728,7 +534,7 @@ func (s *NantunSportCenterService) fastBookCourt(page *rod.Page, buttonIndex int
                 console.error(e);
                 return false;
             }
-        }`, matches[2])
+        }`, params.Date.Format(types.DateLayout))
 
 		// 執行確認按鈕點擊
 		confirmResult, err := page.Eval(confirmScript)
@@ -742,7 +548,9 @@ func (s *NantunSportCenterService) fastBookCourt(page *rod.Page, buttonIndex int
 		}
 
 		// 等待最終確認頁面載入
-		page.MustWaitStable()
+		if err := waitStable(page); err != nil {
+			return err
+		}
 		logger.Log.Info("成功預約場地")
 
 		// 返回首頁
@@ -766,8 +574,12 @@ func (s *NantunSportCenterService) fastBookCourt(page *rod.Page, buttonIndex int
 			return fmt.Errorf("返回首頁失敗")
 		}
 
-		page.MustWaitStable()
-		logger.Log.Info("成功返回首頁")
+		// 預約已成功，返回首頁失敗只記錄
+		if err := waitStable(page); err != nil {
+			logger.Log.Warn("返回首頁後等待頁面失敗: " + err.Error())
+		} else {
+			logger.Log.Info("成功返回首頁")
+		}
 
 		return nil
 	}
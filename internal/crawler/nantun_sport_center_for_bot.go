@@ -1,65 +1,240 @@
 package crawler
 
 import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
 	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/proto"
 	"github.com/tian841224/crawler_sportcenter/internal/browser"
 	"github.com/tian841224/crawler_sportcenter/internal/types"
-	"github.com/tian841224/crawler_sportcenter/pkg/config"
+	"github.com/tian841224/crawler_sportcenter/pkg/logger"
+	"go.uber.org/zap"
 )
 
-type NantunSportCenterBotInterface interface {
-	GetAvailableTimeSlots(weedday string, time_slot int, tag string) ([]types.CleanTimeSlot, error)
-	GetAvailableTimeSlotsForSchedule(weekday string, time_slot int, tag string) ([]types.CleanTimeSlot, error)
-	BookCourt(targetSlot []types.CleanTimeSlot) error
-	GetPaymentURL() string
-}
+var (
+	_ SportCenterProvider = (*NantunSportCenterBotService)(nil)
+	_ SessionKeeper       = (*NantunSportCenterBotService)(nil)
+)
 
-var _ NantunSportCenterBotInterface = (*NantunSportCenterBotService)(nil)
+// 會員頁面使用的分頁標籤後綴，與預約頁面分開
+const memberPageSuffix = ":member"
 
 type NantunSportCenterBotService struct {
-	browserService           browser.BrowserService
+	browserService           *browser.BrowserService
 	nantunSportCenterService NantunSportCenterService
 	Nantun_Url               string // 南屯運動中心網址
 	paymentURL               string // 付款網址
-	cfg                      config.Config
-	page                     *rod.Page
-	tagList                  map[string]struct{}
+	credentials              CredentialResolver
 }
 
-func NewNantunSportCenterBotService(browserService browser.BrowserService, nantunSportCenterService NantunSportCenterService, cfg config.Config) NantunSportCenterBotService {
-	return NantunSportCenterBotService{
+// 每次呼叫各自借用標籤的分頁，不保存頁面狀態，可同時由 Bot 與排程呼叫
+func NewNantunSportCenterBotService(browserService *browser.BrowserService, nantunSportCenterService NantunSportCenterService, credentials CredentialResolver) *NantunSportCenterBotService {
+	return &NantunSportCenterBotService{
 		browserService:           browserService,
 		nantunSportCenterService: nantunSportCenterService,
 		Nantun_Url:               "https://nd01.xuanen.com.tw/BPMember/BPMemberLogin",
 		paymentURL:               "https://nd01.xuanen.com.tw/BPMemberOrder/BPMemberOrder",
-		cfg:                      cfg,
-		tagList:                  make(map[string]struct{}),
+		credentials:              credentials,
 	}
 }
 
+func (s *NantunSportCenterBotService) ID() types.VenueID {
+	return types.VenueNantun
+}
+
+func (s *NantunSportCenterBotService) Name() string {
+	return "南屯運動中心"
+}
+
+// 目前僅支援羽球場
+func (s *NantunSportCenterBotService) GetFacilities() []types.Facility {
+	return []types.Facility{{ID: "badminton", Name: "羽球"}}
+}
+
 func (s *NantunSportCenterBotService) GetPaymentURL() string {
 	return s.paymentURL
 }
 
-func (s *NantunSportCenterBotService) GetAvailableTimeSlots(weekday string, time_slot int, tag string) ([]types.CleanTimeSlot, error) {
+func (s *NantunSportCenterBotService) GetAvailableTimeSlots(date time.Time, time_slot int, tag string) ([]types.Slot, error) {
 
 	timeSlotCode := types.TimeSlotCode(time_slot) // 將 int 轉換為 TimeSlotCode
 
-	var err error
+	var targetSlot []types.Slot
+	err := s.withBookingPage(tag, func(page *rod.Page, fresh bool) error {
+		// 已存在的頁面先返回首頁重新進入預約流程
+		if !fresh {
+			if err := s.enterBookingList(page); err != nil {
+				return err
+			}
+		}
+
+		if err := s.nantunSportCenterService.selectDate(page, date); err != nil {
+			return err
+		}
+
+		if err := s.nantunSportCenterService.selectTimeSlot(page, timeSlotCode); err != nil {
+			return err
+		}
+
+		cleanSlots, err := s.nantunSportCenterService.getAllAvailableTimeSlots(page)
+		if err != nil {
+			return err
+		}
 
-	s.page, err = s.browserService.GetPage(s.Nantun_Url, tag)
+		targetSlot = s.nantunSportCenterService.findAvailableCourtsByTimeSlot(cleanSlots, timeSlotCode)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	// 如果頁面已存在的話，跳過以下步驟
-	if !s.hasTag(tag) {
-		s.tagList[tag] = struct{}{}
-		if err = s.nantunSportCenterService.login(s.page, s.cfg); err != nil {
-			return nil, err
+	return targetSlot, nil
+}
+
+func (s *NantunSportCenterBotService) GetAvailableTimeSlotsForSchedule(date time.Time, time_slot int, tag string) ([]types.Slot, error) {
+
+	timeSlotCode := types.TimeSlotCode(time_slot) // 將 int 轉換為 TimeSlotCode
+
+	var targetSlot []types.Slot
+	err := s.withBookingPage(tag, func(page *rod.Page, fresh bool) error {
+		if err := s.ensureBookingList(page, fresh); err != nil {
+			return err
+		}
+
+		if err := s.nantunSportCenterService.selectDate(page, date); err != nil {
+			return err
 		}
+
+		if err := s.nantunSportCenterService.selectTimeSlot(page, timeSlotCode); err != nil {
+			return err
+		}
+
+		cleanSlots, err := s.nantunSportCenterService.getAllAvailableTimeSlots(page)
+		if err != nil {
+			return err
+		}
+
+		targetSlot = s.nantunSportCenterService.findAvailableCourtsByTimeSlot(cleanSlots, timeSlotCode)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
+	return targetSlot, nil
+}
+
+// 取得網站目前開放預約的日期
+func (s *NantunSportCenterBotService) GetBookableDates(tag string) ([]time.Time, error) {
+	var dates []time.Time
+	err := s.withBookingPage(tag, func(page *rod.Page, fresh bool) error {
+		if err := s.ensureBookingList(page, fresh); err != nil {
+			return err
+		}
+
+		var err error
+		dates, err = s.nantunSportCenterService.getBookableDates(page)
+		return err
+	})
+	return dates, err
+}
+
+// 沿用的頁面不在日期選擇頁時（例如預約完成後停在首頁），重新進入預約流程
+func (s *NantunSportCenterBotService) ensureBookingList(page *rod.Page, fresh bool) error {
+	if fresh {
+		return nil
+	}
+
+	has, _, err := page.Has(".datebox")
+	if err != nil || has {
+		return err
+	}
+	logger.Log.Info("頁面不在日期選擇頁，重新進入預約流程")
+	return s.enterBookingList(page)
+}
+
+// 借用該標籤的預約頁面執行操作，操作途中被導回登入頁時重新登入後再試一次
+// fresh 表示頁面剛登入並停在日期選擇頁
+func (s *NantunSportCenterBotService) withBookingPage(tag string, fn func(page *rod.Page, fresh bool) error) error {
+	lease, fresh, err := s.prepareBookingPage(tag)
+	if err != nil {
+		return err
+	}
+	defer lease.Release()
+
+	err = fn(lease.Page, fresh)
+	if err == nil || !s.nantunSportCenterService.isLoggedOut(lease.Page) {
+		return err
+	}
+
+	logger.Log.Warn("登入已逾時，重新登入", zap.String("tag", tag), zap.Error(err))
+	if err = s.relogin(lease.Page, tag); err == nil {
+		err = fn(lease.Page, true)
+	}
+	if err != nil {
+		lease.Discard()
+	}
+	return err
+}
+
+// 借用該標籤的頁面，新分頁或已被登出的頁面先登入並前往日期選擇頁，使用完畢需歸還
+func (s *NantunSportCenterBotService) prepareBookingPage(tag string) (*browser.Lease, bool, error) {
+	lease, err := s.browserService.Acquire(context.Background(), s.Nantun_Url, pageTag(s.ID(), tag))
+	if err != nil {
+		return nil, false, err
+	}
+
+	switch {
+	case lease.New:
+		err = s.openBookingPage(lease.Page, tag)
+	case s.nantunSportCenterService.isLoggedOut(lease.Page):
+		logger.Log.Warn("登入已逾時，重新登入", zap.String("tag", tag))
+		err = s.relogin(lease.Page, tag)
+	default:
+		// 如果頁面已存在且仍在登入狀態，跳過以下步驟
+		return lease, false, nil
+	}
+
+	// 任一步驟失敗時關閉分頁，避免下次沿用停在中途的頁面
+	if err != nil {
+		lease.Discard()
+		return nil, false, err
+	}
+	return lease, true, nil
+}
+
+// 登入並從首頁前往日期選擇頁
+func (s *NantunSportCenterBotService) openBookingPage(page *rod.Page, tag string) error {
+	if err := s.login(page, tag); err != nil {
+		return err
+	}
+	return s.enterBookingList(page)
+}
+
+// 回到登入頁重新登入，再前往日期選擇頁
+func (s *NantunSportCenterBotService) relogin(page *rod.Page, tag string) error {
+	has, _, err := page.Has("#txt_Account")
+	if err != nil {
+		return err
+	}
+	if !has {
+		if err := page.Navigate(s.Nantun_Url); err != nil {
+			return err
+		}
+		if err := waitStable(page); err != nil {
+			return err
+		}
+	}
+	return s.openBookingPage(page, tag)
+}
+
+// 從首頁前往日期選擇頁
+func (s *NantunSportCenterBotService) enterBookingList(page *rod.Page) error {
 	// 點擊首頁按鈕返回
 	script := `() => {
 		try {
@@ -72,127 +247,238 @@ func (s *NantunSportCenterBotService) GetAvailableTimeSlots(weekday string, time
 	}`
 
 	// 執行返回首頁腳本
-	s.page.Eval(script)
+	page.Eval(script)
 
-	if err = s.nantunSportCenterService.clickAgreeButton(s.page); err != nil {
-		return nil, err
+	if err := s.nantunSportCenterService.clickAgreeButton(page); err != nil {
+		return err
 	}
 
-	if err = s.nantunSportCenterService.selectLocationBooking(s.page); err != nil {
-		return nil, err
+	if err := s.nantunSportCenterService.selectLocationBooking(page); err != nil {
+		return err
 	}
 
-	if err = s.nantunSportCenterService.selectBadminton(s.page); err != nil {
-		return nil, err
+	if err := s.nantunSportCenterService.selectBadminton(page); err != nil {
+		return err
 	}
 
-	if err = s.nantunSportCenterService.setCheckboxAndProceed(s.page); err != nil {
-		return nil, err
+	if err := s.nantunSportCenterService.setCheckboxAndProceed(page); err != nil {
+		return err
 	}
 
-	if err = s.nantunSportCenterService.proceedToBooking(s.page); err != nil {
-		return nil, err
-	}
+	return s.nantunSportCenterService.proceedToBooking(page)
+}
 
-	if err = s.nantunSportCenterService.selectDate(s.page, weekday); err != nil {
-		return nil, err
+// 以使用者自己的帳密登入
+func (s *NantunSportCenterBotService) login(page *rod.Page, tag string) error {
+	cred, err := s.credentials.Resolve(context.Background(), tag)
+	if err != nil {
+		return err
 	}
 
-	if err = s.nantunSportCenterService.selectTimeSlot(s.page, timeSlotCode); err != nil {
-		return nil, err
+	return s.nantunSportCenterService.login(page, cred)
+}
+
+// KeepAlive 在已開啟的預約頁面背景送出請求，延長網站的登入時效
+// 頁面不存在或使用中時略過，已被登出時重新登入
+func (s *NantunSportCenterBotService) KeepAlive(tag string) error {
+	lease, ok := s.browserService.TryAcquire(pageTag(s.ID(), tag))
+	if !ok {
+		return nil
 	}
+	defer lease.Release()
 
-	cleanSlots, err := s.nantunSportCenterService.getAllAvailableTimeSlots(s.page)
+	result, err := lease.Page.Eval(`() => fetch('/BPHome/BPHome', { credentials: 'include' }).then(res => res.url)`)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	if !strings.Contains(result.Value.Str(), nantunLoginPath) && !s.nantunSportCenterService.isLoggedOut(lease.Page) {
+		return nil
 	}
 
-	targetSlot := s.nantunSportCenterService.findAvailableCourtsByTimeSlot(cleanSlots, timeSlotCode)
-
-	return targetSlot, nil
+	logger.Log.Warn("登入已逾時，重新登入", zap.String("tag", tag))
+	if err := s.relogin(lease.Page, tag); err != nil {
+		lease.Discard()
+		return err
+	}
+	return nil
 }
 
-func (s *NantunSportCenterBotService) GetAvailableTimeSlotsForSchedule(weekday string, time_slot int, tag string) ([]types.CleanTimeSlot, error) {
-
-	timeSlotCode := types.TimeSlotCode(time_slot) // 將 int 轉換為 TimeSlotCode
+func (s *NantunSportCenterBotService) BookCourt(targetSlot []types.Slot, pref types.CourtPreference, tag string) (*types.Slot, error) {
+	// 查詢可能走 HTTP，頁面不存在時先登入並前往預約頁
+	var booked *types.Slot
+	err := s.withBookingPage(tag, func(page *rod.Page, fresh bool) error {
+		var err error
+		booked, err = s.nantunSportCenterService.bookCourt(page, targetSlot, pref)
+		return err
+	})
+	return booked, err
+}
 
-	var err error
+// 在會員訂單頁找到相符的訂單並按下取消
+func (s *NantunSportCenterBotService) CancelBooking(order types.Order, tag string) error {
+	lease, err := s.openMemberPage(s.paymentURL, tag)
+	if err != nil {
+		return err
+	}
+	defer lease.Release()
+	page := lease.Page
 
-	s.page, err = s.browserService.GetPage(s.Nantun_Url, tag)
+	row, err := s.findOrderRow(page, order)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	if row == nil {
+		return fmt.Errorf("找不到此預約的訂單")
+	}
+	if row.cancelScript == "" && row.cancelURL == "" {
+		return fmt.Errorf("此訂單無法取消")
 	}
 
-	// 如果頁面已存在的話，跳過以下步驟
-	if !s.hasTag(tag) {
-		s.tagList[tag] = struct{}{}
-		if err = s.nantunSportCenterService.login(s.page, s.cfg); err != nil {
-			return nil, err
+	if row.cancelURL != "" {
+		info, err := page.Info()
+		if err != nil {
+			return err
 		}
-
-		// 點擊首頁按鈕返回
-		script := `() => {
+		cancelURL, err := url.Parse(info.URL)
+		if err != nil {
+			return err
+		}
+		if cancelURL, err = cancelURL.Parse(row.cancelURL); err != nil {
+			return err
+		}
+		if err := page.Navigate(cancelURL.String()); err != nil {
+			return err
+		}
+	} else {
+		// 網站以 confirm 詢問是否取消，先改為自動確認
+		script := fmt.Sprintf(`() => {
 			try {
-				window.location = '/BPHome/BPHome';
+				window.confirm = () => true;
+				window.alert = () => {};
+				%s;
 				return true;
 			} catch (e) {
 				console.error(e);
 				return false;
 			}
-		}`
-
-		// 執行返回首頁腳本
-		s.page.Eval(script)
+		}`, row.cancelScript)
 
-		if err = s.nantunSportCenterService.clickAgreeButton(s.page); err != nil {
-			return nil, err
+		result, err := page.Eval(script)
+		if err != nil {
+			logger.Log.Error(fmt.Sprintf("執行取消腳本失敗: %s", err))
+			return err
 		}
-
-		if err = s.nantunSportCenterService.selectLocationBooking(s.page); err != nil {
-			return nil, err
+		if !result.Value.Bool() {
+			return fmt.Errorf("取消腳本執行失敗")
 		}
+	}
+	if err := waitStable(page); err != nil {
+		return err
+	}
 
-		if err = s.nantunSportCenterService.selectBadminton(s.page); err != nil {
-			return nil, err
+	// 取消確認視窗
+	if button, err := page.Timeout(3 * time.Second).Element("button.swal2-confirm"); err == nil {
+		if err := button.CancelTimeout().Click(proto.InputMouseButtonLeft, 1); err == nil {
+			if err := waitStable(page); err != nil {
+				return err
+			}
 		}
+	}
 
-		if err = s.nantunSportCenterService.setCheckboxAndProceed(s.page); err != nil {
-			return nil, err
-		}
+	// 重新讀取訂單頁確認已取消
+	if err := page.Navigate(s.paymentURL); err != nil {
+		return err
+	}
+	if err := waitStable(page); err != nil {
+		return err
+	}
 
-		if err = s.nantunSportCenterService.proceedToBooking(s.page); err != nil {
-			return nil, err
-		}
+	row, err = s.findOrderRow(page, order)
+	if err != nil {
+		return err
+	}
+	if row != nil && row.order.Active() {
+		return fmt.Errorf("取消後訂單仍然有效")
 	}
 
-	if err = s.nantunSportCenterService.selectDate(s.page, weekday); err != nil {
+	logger.Log.Info(fmt.Sprintf("已取消訂單：%s %s", order.Start.Format("2006-01-02 15:04"), order.CourtName))
+	return nil
+}
+
+// 找出頁面上與訂單相符且仍有效的列
+func (s *NantunSportCenterBotService) findOrderRow(page *rod.Page, order types.Order) (*nantunOrderRow, error) {
+	html, err := page.HTML()
+	if err != nil {
 		return nil, err
 	}
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range parseNantunOrderRows(doc) {
+		if row.order.Active() && row.order.SameAs(order) {
+			return &row, nil
+		}
+	}
+	return nil, nil
+}
 
-	if err = s.nantunSportCenterService.selectTimeSlot(s.page, timeSlotCode); err != nil {
+// 以獨立分頁開啟會員訂單頁，避免離開預約頁面
+func (s *NantunSportCenterBotService) GetOrders(tag string) ([]types.Order, error) {
+	lease, err := s.openMemberPage(s.paymentURL, tag)
+	if err != nil {
 		return nil, err
 	}
+	defer lease.Release()
 
-	cleanSlots, err := s.nantunSportCenterService.getAllAvailableTimeSlots(s.page)
+	html, err := lease.Page.HTML()
 	if err != nil {
 		return nil, err
 	}
+	return ParseNantunOrders(strings.NewReader(html))
+}
 
-	targetSlot := s.nantunSportCenterService.findAvailableCourtsByTimeSlot(cleanSlots, timeSlotCode)
+// 借用會員頁面並前往網址，被導回登入頁時以使用者的帳密登入後重新前往
+func (s *NantunSportCenterBotService) openMemberPage(rawURL string, tag string) (*browser.Lease, error) {
+	lease, err := s.browserService.Acquire(context.Background(), rawURL, pageTag(s.ID(), tag)+memberPageSuffix)
+	if err != nil {
+		return nil, err
+	}
 
-	return targetSlot, nil
+	if err := s.loginMemberPage(lease.Page, rawURL, tag); err != nil {
+		lease.Discard()
+		return nil, err
+	}
+	return lease, nil
 }
 
-// 新增：檢查標籤是否存在
-func (s *NantunSportCenterBotService) hasTag(tag string) bool {
-	// 直接檢查 map 中是否存在該 key
-	_, exists := s.tagList[tag]
-	return exists
-}
+// 前往會員頁面，尚未登入時登入後重新前往
+func (s *NantunSportCenterBotService) loginMemberPage(page *rod.Page, rawURL string, tag string) error {
+	if err := page.Navigate(rawURL); err != nil {
+		return err
+	}
+	if err := waitStable(page); err != nil {
+		return err
+	}
 
-func (s *NantunSportCenterBotService) BookCourt(targetSlot []types.CleanTimeSlot) error {
-	if err := s.nantunSportCenterService.bookCourt(s.page, targetSlot); err != nil {
+	has, _, err := page.Has("#txt_Account")
+	if err != nil {
 		return err
 	}
-	return nil
+	if !has {
+		return nil
+	}
+
+	cred, err := s.credentials.Resolve(context.Background(), tag)
+	if err != nil {
+		return err
+	}
+	if err := s.nantunSportCenterService.login(page, cred); err != nil {
+		return err
+	}
+	if err := page.Navigate(rawURL); err != nil {
+		return err
+	}
+	return waitStable(page)
 }
@@ -0,0 +1,112 @@
+package crawler
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/go-rod/rod/lib/launcher"
+	"github.com/tian841224/crawler_sportcenter/internal/browser"
+	"github.com/tian841224/crawler_sportcenter/internal/types"
+)
+
+// 需要本機已安裝的瀏覽器，找不到時略過，不自動下載
+func newTestBrowser(t *testing.T, maxPages int) *browser.BrowserService {
+	t.Helper()
+	path, found := launcher.LookPath()
+	if !found {
+		t.Skip("找不到瀏覽器")
+	}
+	service := browser.NewBrowserService(browser.Options{MaxPages: maxPages, Headless: true, BinPath: path})
+	t.Cleanup(func() { service.Close() })
+	return service
+}
+
+func TestNantunBotSerializesLoginPerTag(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts a browser")
+	}
+	server := newFakeNantunServer(t)
+	browserService := newTestBrowser(t, 4)
+	bot := NewNantunSportCenterBotService(browserService, NewNantunSportCenterService(browserService), fakeCredentials{})
+	bot.paymentURL = server.URL + nantunOrderPath
+
+	var wg sync.WaitGroup
+	for _, tag := range []string{"100", "100", "100", "200", "200"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			orders, err := bot.GetOrders(tag)
+			if err != nil {
+				t.Errorf("GetOrders(%s): %v", tag, err)
+				return
+			}
+			if len(orders) == 0 {
+				t.Errorf("GetOrders(%s) returned no orders", tag)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// 同一標籤共用一個已登入的分頁，只登入一次；不同標籤使用各自的無痕環境
+	for _, account := range []string{"100", "200"} {
+		logins, overlap := server.loginCount(account)
+		if logins != 1 || overlap {
+			t.Errorf("account %s: logins = %d, overlap = %v, want a single serialized login", account, logins, overlap)
+		}
+	}
+
+	// 分頁仍保留登入狀態，之後的查詢不需再登入
+	if _, err := bot.GetOrders("100"); err != nil {
+		t.Fatalf("GetOrders after login: %v", err)
+	}
+	if logins, _ := server.loginCount("100"); logins != 1 {
+		t.Errorf("logins = %d after reusing the page, want 1", logins)
+	}
+}
+
+// 預約完成後頁面停在首頁，之後同一標籤的排程查詢需重新進入日期選擇頁
+func TestNantunBotQueriesAfterBookingOnSameTag(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts a browser")
+	}
+	server := newFakeNantunServer(t)
+	browserService := newTestBrowser(t, 2)
+	bot := NewNantunSportCenterBotService(browserService, NewNantunSportCenterService(browserService), fakeCredentials{})
+	bot.Nantun_Url = server.URL + nantunLoginPath
+
+	slots, err := bot.GetAvailableTimeSlotsForSchedule(fixtureDate(3), int(types.TimeSlot_19_20), "100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(slots) != 2 {
+		t.Fatalf("got %d slots, want 2: %+v", len(slots), slots)
+	}
+
+	booked, err := bot.BookCourt(slots, types.CourtPreference{}, "100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if booked.CourtName != "羽球A場" {
+		t.Errorf("booked %s, want 羽球A場", booked.CourtName)
+	}
+
+	slots, err = bot.GetAvailableTimeSlotsForSchedule(fixtureDate(3), int(types.TimeSlot_19_20), "100")
+	if err != nil {
+		t.Fatalf("query after booking: %v", err)
+	}
+	if len(slots) != 2 {
+		t.Errorf("got %d slots after booking, want 2", len(slots))
+	}
+	if _, err := bot.GetBookableDates("100"); err != nil {
+		t.Errorf("bookable dates after booking: %v", err)
+	}
+
+	if logins, _ := server.loginCount("100"); logins != 1 {
+		t.Errorf("logins = %d, want the page reused without logging in again", logins)
+	}
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	if server.bookings != 1 {
+		t.Errorf("bookings = %d, want 1", server.bookings)
+	}
+}
@@ -0,0 +1,118 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/tian841224/crawler_sportcenter/internal/types"
+	"github.com/tian841224/crawler_sportcenter/pkg/logger"
+	"go.uber.org/zap"
+)
+
+var (
+	_ SportCenterProvider = (*NantunHTTPService)(nil)
+	_ SessionKeeper       = (*NantunHTTPService)(nil)
+)
+
+// NantunHTTPService 以 HTTP 查詢南屯場地，失敗時改用瀏覽器
+// 預約與取消仍透過瀏覽器執行
+type NantunHTTPService struct {
+	client   *NantunHTTPClient
+	fallback SportCenterProvider
+}
+
+func NewNantunHTTPService(client *NantunHTTPClient, fallback SportCenterProvider) *NantunHTTPService {
+	return &NantunHTTPService{
+		client:   client,
+		fallback: fallback,
+	}
+}
+
+func (s *NantunHTTPService) ID() types.VenueID {
+	return s.fallback.ID()
+}
+
+func (s *NantunHTTPService) Name() string {
+	return s.fallback.Name()
+}
+
+func (s *NantunHTTPService) GetFacilities() []types.Facility {
+	return s.fallback.GetFacilities()
+}
+
+func (s *NantunHTTPService) GetPaymentURL() string {
+	return s.fallback.GetPaymentURL()
+}
+
+func (s *NantunHTTPService) GetAvailableTimeSlots(date time.Time, time_slot int, tag string) ([]types.Slot, error) {
+	slots, err := s.query(date, time_slot, tag)
+	if err == nil || errors.Is(err, ErrCredentialNotSet) {
+		return slots, err
+	}
+	logger.Log.Warn("HTTP 查詢失敗，改用瀏覽器", zap.String("tag", tag), zap.Error(err))
+	return s.fallback.GetAvailableTimeSlots(date, time_slot, tag)
+}
+
+// HTTP 查詢沒有頁面狀態，排程與手動查詢流程相同
+func (s *NantunHTTPService) GetAvailableTimeSlotsForSchedule(date time.Time, time_slot int, tag string) ([]types.Slot, error) {
+	slots, err := s.query(date, time_slot, tag)
+	if err == nil || errors.Is(err, ErrCredentialNotSet) {
+		return slots, err
+	}
+	logger.Log.Warn("HTTP 查詢失敗，改用瀏覽器", zap.String("tag", tag), zap.Error(err))
+	return s.fallback.GetAvailableTimeSlotsForSchedule(date, time_slot, tag)
+}
+
+func (s *NantunHTTPService) GetBookableDates(tag string) ([]time.Time, error) {
+	dates, err := s.client.GetBookableDates(context.Background(), tag)
+	if err == nil || errors.Is(err, ErrCredentialNotSet) {
+		return dates, err
+	}
+	logger.Log.Warn("HTTP 查詢開放日期失敗，改用瀏覽器", zap.String("tag", tag), zap.Error(err))
+	return s.fallback.GetBookableDates(tag)
+}
+
+func (s *NantunHTTPService) BookCourt(targetSlot []types.Slot, pref types.CourtPreference, tag string) (*types.Slot, error) {
+	return s.fallback.BookCourt(targetSlot, pref, tag)
+}
+
+func (s *NantunHTTPService) CancelBooking(order types.Order, tag string) error {
+	return s.fallback.CancelBooking(order, tag)
+}
+
+func (s *NantunHTTPService) GetOrders(tag string) ([]types.Order, error) {
+	orders, err := s.client.GetOrders(context.Background(), tag)
+	if err == nil || errors.Is(err, ErrCredentialNotSet) {
+		return orders, err
+	}
+	logger.Log.Warn("HTTP 查詢訂單失敗，改用瀏覽器", zap.String("tag", tag), zap.Error(err))
+	return s.fallback.GetOrders(tag)
+}
+
+// KeepAlive 同時保持 HTTP 與瀏覽器的登入狀態
+func (s *NantunHTTPService) KeepAlive(tag string) error {
+	err := s.client.KeepAlive(context.Background(), tag)
+	if keeper, ok := s.fallback.(SessionKeeper); ok {
+		err = errors.Join(err, keeper.KeepAlive(tag))
+	}
+	return err
+}
+
+// 以 HTTP 查詢並篩選指定時段
+func (s *NantunHTTPService) query(date time.Time, time_slot int, tag string) ([]types.Slot, error) {
+	timeSlotCode := types.TimeSlotCode(time_slot)
+
+	cleanSlots, err := s.client.GetAvailableTimeSlots(context.Background(), date, timeSlotCode.DayPeriod(), tag)
+	if err != nil {
+		return nil, err
+	}
+
+	var availableCourts []types.Slot
+	for _, slot := range cleanSlots {
+		if slot.Matches(timeSlotCode) {
+			availableCourts = append(availableCourts, slot)
+		}
+	}
+	return availableCourts, nil
+}
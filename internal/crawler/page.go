@@ -0,0 +1,31 @@
+package crawler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-rod/rod"
+)
+
+// 等待頁面元素或載入的最長時間，逾時回傳錯誤而非一直等待
+const pageTimeout = 30 * time.Second
+
+// 等待頁面載入並穩定，網站沒有回應時回傳錯誤，由呼叫端決定重新登入或關閉分頁
+func waitStable(page *rod.Page) error {
+	timed := page.Timeout(pageTimeout)
+	defer timed.CancelTimeout()
+
+	if err := timed.WaitStable(time.Second); err != nil {
+		return fmt.Errorf("等待頁面穩定失敗: %w", err)
+	}
+	return nil
+}
+
+// 等待元素出現，回傳的元素不受逾時限制
+func findElement(page *rod.Page, selector string) (*rod.Element, error) {
+	element, err := page.Timeout(pageTimeout).Element(selector)
+	if err != nil {
+		return nil, fmt.Errorf("找不到元素 %s: %w", selector, err)
+	}
+	return element.CancelTimeout(), nil
+}
@@ -0,0 +1,78 @@
+package crawler
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tian841224/crawler_sportcenter/internal/types"
+)
+
+// ErrNotSupported 運動中心尚未支援此功能
+var ErrNotSupported = errors.New("此運動中心尚未支援此功能")
+
+// SportCenterProvider 運動中心共用介面，Bot 與排程透過此介面操作各場館
+type SportCenterProvider interface {
+	ID() types.VenueID
+	Name() string
+	GetFacilities() []types.Facility
+	GetAvailableTimeSlots(date time.Time, time_slot int, tag string) ([]types.Slot, error)
+	GetAvailableTimeSlotsForSchedule(date time.Time, time_slot int, tag string) ([]types.Slot, error)
+	GetBookableDates(tag string) ([]time.Time, error)
+	BookCourt(targetSlot []types.Slot, pref types.CourtPreference, tag string) (*types.Slot, error) // 依場地偏好嘗試，回傳實際預約的場地
+	CancelBooking(order types.Order, tag string) error
+	GetOrders(tag string) ([]types.Order, error) // 會員訂單與付款狀態
+	GetPaymentURL() string
+}
+
+// SessionKeeper 可定期保持登入狀態的運動中心，避免網站登入逾時
+type SessionKeeper interface {
+	KeepAlive(tag string) error
+}
+
+// 分頁池的標籤，同一使用者在不同場館使用各自的分頁與登入狀態
+func pageTag(venueID types.VenueID, tag string) string {
+	return string(venueID) + ":" + tag
+}
+
+// ProviderRegistry 以場館代碼管理所有運動中心
+type ProviderRegistry struct {
+	providers map[types.VenueID]SportCenterProvider
+	order     []types.VenueID
+}
+
+func NewProviderRegistry(providers ...SportCenterProvider) *ProviderRegistry {
+	r := &ProviderRegistry{
+		providers: make(map[types.VenueID]SportCenterProvider),
+	}
+	for _, p := range providers {
+		r.Register(p)
+	}
+	return r
+}
+
+// Register 註冊運動中心，相同代碼會覆蓋舊的實作
+func (r *ProviderRegistry) Register(provider SportCenterProvider) {
+	if _, exists := r.providers[provider.ID()]; !exists {
+		r.order = append(r.order, provider.ID())
+	}
+	r.providers[provider.ID()] = provider
+}
+
+// Get 依場館代碼取得運動中心
+func (r *ProviderRegistry) Get(id types.VenueID) (SportCenterProvider, error) {
+	provider, exists := r.providers[id]
+	if !exists {
+		return nil, fmt.Errorf("找不到場館: %s", id)
+	}
+	return provider, nil
+}
+
+// List 依註冊順序列出所有運動中心
+func (r *ProviderRegistry) List() []SportCenterProvider {
+	list := make([]SportCenterProvider, 0, len(r.order))
+	for _, id := range r.order {
+		list = append(list, r.providers[id])
+	}
+	return list
+}
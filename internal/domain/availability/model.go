@@ -0,0 +1,26 @@
+package availability
+
+import "time"
+
+// Snapshot 場地在指定日期與時段的最後已知狀態
+type Snapshot struct {
+	ID         uint      `gorm:"primaryKey;column:id;autoIncrement" json:"id"`
+	VenueID    string    `gorm:"column:venue_id;type:varchar(20);not null;uniqueIndex:idx_snapshot_court" json:"venueId"`
+	Date       string    `gorm:"column:date;type:varchar(10);not null;uniqueIndex:idx_snapshot_court" json:"date"` // 格式 2006-01-02
+	TimeSlotID uint      `gorm:"column:time_slot_id;not null;uniqueIndex:idx_snapshot_court" json:"timeSlotId"`
+	CourtName  string    `gorm:"column:court_name;type:varchar(50);not null;uniqueIndex:idx_snapshot_court" json:"courtName"`
+	Available  bool      `gorm:"column:available;not null" json:"available"`
+	CreatedAt  time.Time `gorm:"column:created_at;autoCreateTime" json:"createdAt" swaggerignore:"true"`
+	UpdatedAt  time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updatedAt" swaggerignore:"true"`
+}
+
+// TableName 設定資料表名稱
+func (Snapshot) TableName() string {
+	return "availability_snapshot"
+}
+
+// Change 與上次狀態比較後的差異
+type Change struct {
+	Gained []string // 由不可預約變為可預約的場地
+	Lost   []string // 由可預約變為不可預約的場地
+}
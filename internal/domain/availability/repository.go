@@ -0,0 +1,51 @@
+package availability
+
+import (
+	"context"
+
+	"github.com/tian841224/crawler_sportcenter/internal/infrastructure/db"
+	"github.com/tian841224/crawler_sportcenter/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+type Repository interface {
+	GetBySlot(ctx context.Context, venueID string, date string, timeSlotID uint) ([]*Snapshot, error)
+	Save(ctx context.Context, snapshot *Snapshot) error
+	DeleteBefore(ctx context.Context, date string) error
+}
+
+type SnapshotRepository struct {
+	db *db.DB
+}
+
+var _ Repository = (*SnapshotRepository)(nil)
+
+func NewSnapshotRepository(db *db.DB) Repository {
+	conn := (*db).GetConn().(*gorm.DB)
+	if err := conn.AutoMigrate(&Snapshot{}); err != nil {
+		logger.Log.Error("資料庫遷移失敗", zap.Error(err))
+		return nil
+	}
+	return &SnapshotRepository{db: db}
+}
+
+func (r *SnapshotRepository) GetBySlot(ctx context.Context, venueID string, date string, timeSlotID uint) ([]*Snapshot, error) {
+	var snapshots []*Snapshot
+	conn := (*r.db).GetConn().(*gorm.DB)
+	err := conn.WithContext(ctx).
+		Where("venue_id = ? AND date = ? AND time_slot_id = ?", venueID, date, timeSlotID).
+		Find(&snapshots).Error
+	return snapshots, err
+}
+
+func (r *SnapshotRepository) Save(ctx context.Context, snapshot *Snapshot) error {
+	conn := (*r.db).GetConn().(*gorm.DB)
+	return conn.WithContext(ctx).Save(snapshot).Error
+}
+
+// DeleteBefore 刪除指定日期以前的狀態
+func (r *SnapshotRepository) DeleteBefore(ctx context.Context, date string) error {
+	conn := (*r.db).GetConn().(*gorm.DB)
+	return conn.WithContext(ctx).Where("date < ?", date).Delete(&Snapshot{}).Error
+}
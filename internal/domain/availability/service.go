@@ -0,0 +1,80 @@
+package availability
+
+import (
+	"context"
+	"errors"
+)
+
+type Service interface {
+	Update(ctx context.Context, venueID string, date string, timeSlotID uint, courts []string) (*Change, error)
+	DeleteBefore(ctx context.Context, date string) error
+}
+
+type SnapshotService struct {
+	repo Repository
+}
+
+var _ Service = (*SnapshotService)(nil)
+
+func NewSnapshotService(repo Repository) Service {
+	return &SnapshotService{repo: repo}
+}
+
+// Update 以本次查到的可預約場地更新狀態，並回傳與上次的差異
+func (s *SnapshotService) Update(ctx context.Context, venueID string, date string, timeSlotID uint, courts []string) (*Change, error) {
+	if venueID == "" || date == "" || timeSlotID == 0 {
+		return nil, errors.New("場館、日期與時段不能為空")
+	}
+
+	snapshots, err := s.repo.GetBySlot(ctx, venueID, date, timeSlotID)
+	if err != nil {
+		return nil, err
+	}
+
+	existing := make(map[string]*Snapshot, len(snapshots))
+	for _, snapshot := range snapshots {
+		existing[snapshot.CourtName] = snapshot
+	}
+
+	available := make(map[string]struct{}, len(courts))
+	change := &Change{}
+
+	for _, court := range courts {
+		if _, duplicated := available[court]; duplicated {
+			continue
+		}
+		available[court] = struct{}{}
+
+		snapshot, exists := existing[court]
+		if exists && snapshot.Available {
+			continue
+		}
+		if !exists {
+			snapshot = &Snapshot{VenueID: venueID, Date: date, TimeSlotID: timeSlotID, CourtName: court}
+		}
+
+		snapshot.Available = true
+		if err := s.repo.Save(ctx, snapshot); err != nil {
+			return nil, err
+		}
+		change.Gained = append(change.Gained, court)
+	}
+
+	for _, snapshot := range snapshots {
+		if _, stillAvailable := available[snapshot.CourtName]; stillAvailable || !snapshot.Available {
+			continue
+		}
+
+		snapshot.Available = false
+		if err := s.repo.Save(ctx, snapshot); err != nil {
+			return nil, err
+		}
+		change.Lost = append(change.Lost, snapshot.CourtName)
+	}
+
+	return change, nil
+}
+
+func (s *SnapshotService) DeleteBefore(ctx context.Context, date string) error {
+	return s.repo.DeleteBefore(ctx, date)
+}
@@ -0,0 +1,121 @@
+package booking
+
+import (
+	"time"
+
+	"github.com/tian841224/crawler_sportcenter/internal/types"
+)
+
+// Source 預約來源
+type Source string
+
+const (
+	SourceManual Source = "manual" // 使用者在 Telegram 手動預約
+	SourceAuto   Source = "auto"   // 訂閱自動預約
+	SourceSniper Source = "sniper" // 開放時間搶場
+)
+
+// Label 顯示文字
+func (s Source) Label() string {
+	switch s {
+	case SourceManual:
+		return "手動"
+	case SourceAuto:
+		return "自動"
+	case SourceSniper:
+		return "搶場"
+	}
+	return string(s)
+}
+
+// Status 預約狀態
+type Status string
+
+const (
+	StatusPendingPayment Status = "pending_payment" // 待付款
+	StatusPaid           Status = "paid"            // 已付款
+	StatusCancelled      Status = "cancelled"       // 已取消
+	StatusExpired        Status = "expired"         // 逾期未付款
+)
+
+// Label 顯示文字
+func (s Status) Label() string {
+	switch s {
+	case StatusPendingPayment:
+		return "待付款"
+	case StatusPaid:
+		return "已付款"
+	case StatusCancelled:
+		return "已取消"
+	case StatusExpired:
+		return "逾期未付款"
+	}
+	return string(s)
+}
+
+// Booking 預約紀錄
+type Booking struct {
+	ID              uint       `gorm:"primaryKey;column:id;autoIncrement" json:"id"`
+	UserID          uint       `gorm:"column:user_id;not null;index" json:"userId"`
+	VenueID         string     `gorm:"column:venue_id;type:varchar(20);not null" json:"venueId"`
+	CourtID         int        `gorm:"column:court_id" json:"courtId"`
+	CourtName       string     `gorm:"column:court_name;type:varchar(50)" json:"courtName"`
+	Date            time.Time  `gorm:"column:date;type:date;not null" json:"date"`
+	StartTime       time.Time  `gorm:"column:start_time" json:"startTime"`
+	EndTime         time.Time  `gorm:"column:end_time" json:"endTime"`
+	Price           int        `gorm:"column:price" json:"price"`
+	Params          string     `gorm:"column:params;type:varchar(64)" json:"params"` // 預約參數，見 types.BookingParams
+	Source          Source     `gorm:"column:source;type:varchar(10);not null" json:"source"`
+	Status          Status     `gorm:"column:status;type:varchar(20);not null;index" json:"status"`
+	OrderID         string     `gorm:"column:order_id;type:varchar(50)" json:"orderId"` // 網站上的訂單編號
+	PaymentDeadline *time.Time `gorm:"column:payment_deadline" json:"paymentDeadline"`  // 繳費期限，逾期網站會自動取消
+	RemindedBefore  int        `gorm:"column:reminded_before" json:"remindedBefore"`    // 已發送的最近一次提醒（距期限分鐘數），0 表示尚未提醒
+	CreatedAt       time.Time  `gorm:"column:created_at;autoCreateTime" json:"createdAt" swaggerignore:"true"`
+	UpdatedAt       time.Time  `gorm:"column:updated_at;autoUpdateTime" json:"updatedAt" swaggerignore:"true"`
+}
+
+// TableName 設定資料表名稱
+func (Booking) TableName() string {
+	return "booking"
+}
+
+// NewFromSlot 以預約成功的場地建立紀錄，狀態為待付款
+func NewFromSlot(userID uint, slot types.Slot, source Source) *Booking {
+	date := slot.Params.Date
+	if !slot.Start.IsZero() {
+		date = slot.Date()
+	}
+	return &Booking{
+		UserID:    userID,
+		VenueID:   string(slot.VenueID),
+		CourtID:   slot.CourtID,
+		CourtName: slot.CourtName,
+		Date:      date,
+		StartTime: slot.Start,
+		EndTime:   slot.End,
+		Price:     slot.Price,
+		Params:    slot.Params.String(),
+		Source:    source,
+		Status:    StatusPendingPayment,
+	}
+}
+
+// Order 轉換為網站訂單，用於比對與取消
+func (b *Booking) Order() types.Order {
+	start := b.StartTime
+	if start.IsZero() {
+		start = types.DateOnly(b.Date)
+	}
+	return types.Order{
+		OrderID:   b.OrderID,
+		CourtName: b.CourtName,
+		Start:     start,
+		End:       b.EndTime,
+		Price:     b.Price,
+	}
+}
+
+// Active 是否仍有效（待付款或已付款）
+func (b *Booking) Active() bool {
+	return b.Status == StatusPendingPayment || b.Status == StatusPaid
+}
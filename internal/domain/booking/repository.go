@@ -0,0 +1,81 @@
+package booking
+
+import (
+	"context"
+
+	"github.com/tian841224/crawler_sportcenter/internal/infrastructure/db"
+	"github.com/tian841224/crawler_sportcenter/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+type Repository interface {
+	Create(ctx context.Context, booking *Booking) error
+	GetByID(ctx context.Context, id uint) (*Booking, error)
+	GetByUserID(ctx context.Context, userID uint, limit int) ([]*Booking, error)
+	GetByStatus(ctx context.Context, status Status) ([]*Booking, error)
+	UpdateStatus(ctx context.Context, id uint, status Status) error
+	Update(ctx context.Context, booking *Booking) error
+}
+
+type BookingRepository struct {
+	db *db.DB
+}
+
+var _ Repository = (*BookingRepository)(nil)
+
+func NewBookingRepository(db *db.DB) Repository {
+	conn := (*db).GetConn().(*gorm.DB)
+	if err := conn.AutoMigrate(&Booking{}); err != nil {
+		logger.Log.Error("資料庫遷移失敗", zap.Error(err))
+		return nil
+	}
+	return &BookingRepository{db: db}
+}
+
+func (r *BookingRepository) Create(ctx context.Context, booking *Booking) error {
+	conn := (*r.db).GetConn().(*gorm.DB)
+	return conn.WithContext(ctx).Create(booking).Error
+}
+
+func (r *BookingRepository) GetByID(ctx context.Context, id uint) (*Booking, error) {
+	res := &Booking{}
+	conn := (*r.db).GetConn().(*gorm.DB)
+	if err := conn.WithContext(ctx).First(res, id).Error; err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// GetByUserID 依日期由新到舊取得使用者的預約
+func (r *BookingRepository) GetByUserID(ctx context.Context, userID uint, limit int) ([]*Booking, error) {
+	var bookings []*Booking
+	conn := (*r.db).GetConn().(*gorm.DB)
+	query := conn.WithContext(ctx).Where("user_id = ?", userID).Order("date DESC, start_time DESC, id DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if err := query.Find(&bookings).Error; err != nil {
+		return nil, err
+	}
+	return bookings, nil
+}
+
+func (r *BookingRepository) GetByStatus(ctx context.Context, status Status) ([]*Booking, error) {
+	var bookings []*Booking
+	conn := (*r.db).GetConn().(*gorm.DB)
+	if err := conn.WithContext(ctx).Where("status = ?", status).Order("id").Find(&bookings).Error; err != nil {
+		return nil, err
+	}
+	return bookings, nil
+}
+
+func (r *BookingRepository) UpdateStatus(ctx context.Context, id uint, status Status) error {
+	conn := (*r.db).GetConn().(*gorm.DB)
+	return conn.WithContext(ctx).Model(&Booking{}).Where("id = ?", id).Update("status", status).Error
+}
+
+func (r *BookingRepository) Update(ctx context.Context, booking *Booking) error {
+	conn := (*r.db).GetConn().(*gorm.DB)
+	return conn.WithContext(ctx).Save(booking).Error
+}
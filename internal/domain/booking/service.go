@@ -0,0 +1,63 @@
+package booking
+
+import (
+	"context"
+	"errors"
+
+	"github.com/tian841224/crawler_sportcenter/internal/types"
+)
+
+type Service interface {
+	Record(ctx context.Context, userID uint, slot types.Slot, source Source) (*Booking, error)
+	GetByID(ctx context.Context, id uint) (*Booking, error)
+	GetByUserID(ctx context.Context, userID uint, limit int) ([]*Booking, error)
+	GetByStatus(ctx context.Context, status Status) ([]*Booking, error)
+	UpdateStatus(ctx context.Context, id uint, status Status) error
+	Update(ctx context.Context, booking *Booking) error
+}
+
+type BookingService struct {
+	repo Repository
+}
+
+var _ Service = (*BookingService)(nil)
+
+func NewBookingService(repo Repository) Service {
+	return &BookingService{repo: repo}
+}
+
+// Record 記錄預約成功的場地
+func (s *BookingService) Record(ctx context.Context, userID uint, slot types.Slot, source Source) (*Booking, error) {
+	if userID == 0 {
+		return nil, errors.New("使用者不能為空")
+	}
+	if slot.VenueID == "" || slot.Params.Date.IsZero() {
+		return nil, errors.New("場館與預約日期不能為空")
+	}
+
+	booking := NewFromSlot(userID, slot, source)
+	if err := s.repo.Create(ctx, booking); err != nil {
+		return nil, err
+	}
+	return booking, nil
+}
+
+func (s *BookingService) GetByID(ctx context.Context, id uint) (*Booking, error) {
+	return s.repo.GetByID(ctx, id)
+}
+
+func (s *BookingService) GetByUserID(ctx context.Context, userID uint, limit int) ([]*Booking, error) {
+	return s.repo.GetByUserID(ctx, userID, limit)
+}
+
+func (s *BookingService) GetByStatus(ctx context.Context, status Status) ([]*Booking, error) {
+	return s.repo.GetByStatus(ctx, status)
+}
+
+func (s *BookingService) UpdateStatus(ctx context.Context, id uint, status Status) error {
+	return s.repo.UpdateStatus(ctx, id, status)
+}
+
+func (s *BookingService) Update(ctx context.Context, booking *Booking) error {
+	return s.repo.Update(ctx, booking)
+}
@@ -1,25 +1,105 @@
 package schedule
 
 import (
+	"fmt"
+	"strings"
 	"time"
 
 	timeslot "github.com/tian841224/crawler_sportcenter/internal/domain/time_slot"
 	"github.com/tian841224/crawler_sportcenter/internal/domain/user"
+	"github.com/tian841224/crawler_sportcenter/internal/types"
 )
 
 // Schedule 使用者設定的排程
 type Schedule struct {
-	ID         uint               `gorm:"primaryKey;column:id;autoIncrement" json:"id"`
-	UserID     uint               `gorm:"column:user_id" json:"userId"`
-	User       *user.User         `gorm:"foreignKey:UserID" json:"user,omitempty"`
-	Weekday    time.Weekday       `gorm:"column:weekday;type:smallint" json:"weekday"`
-	TimeSlotID *uint              `gorm:"column:time_slot_id" json:"timeSlotId"`
-	TimeSlot   *timeslot.TimeSlot `gorm:"foreignKey:TimeSlotID" json:"timeSlot,omitempty"`
-	CreatedAt  time.Time          `gorm:"column:created_at;autoCreateTime" json:"createdAt" swaggerignore:"true"`
-	UpdatedAt  time.Time          `gorm:"column:updated_at;autoUpdateTime" json:"updatedAt" swaggerignore:"true"`
+	ID                 uint               `gorm:"primaryKey;column:id;autoIncrement" json:"id"`
+	UserID             uint               `gorm:"column:user_id" json:"userId"`
+	User               *user.User         `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	VenueID            string             `gorm:"column:venue_id;type:varchar(20);not null;default:nantun" json:"venueId"`
+	Weekday            time.Weekday       `gorm:"column:weekday;type:smallint" json:"weekday"`
+	TimeSlotID         *uint              `gorm:"column:time_slot_id" json:"timeSlotId"`
+	TimeSlot           *timeslot.TimeSlot `gorm:"foreignKey:TimeSlotID" json:"timeSlot,omitempty"`
+	Date               *time.Time         `gorm:"column:date;type:date" json:"date"`                                         // 指定日期，設定後只檢查當天
+	StartDate          *time.Time         `gorm:"column:start_date;type:date" json:"startDate"`                              // 開始日期，之前不檢查
+	EndDate            *time.Time         `gorm:"column:end_date;type:date" json:"endDate"`                                  // 到期日，過期後自動刪除
+	AutoBook           bool               `gorm:"column:auto_book;not null;default:false" json:"autoBook"`                   // 有空場地時自動預約
+	MaxPrice           int                `gorm:"column:max_price;not null;default:0" json:"maxPrice"`                       // 自動預約最高價格，0 表示不限
+	CourtPreference    string             `gorm:"column:court_preference;type:varchar(255)" json:"courtPreference"`          // 偏好場地，格式同使用者場地偏好，優先於使用者設定
+	MaxBookingsPerWeek int                `gorm:"column:max_bookings_per_week;not null;default:0" json:"maxBookingsPerWeek"` // 每週自動預約上限，0 表示不限
+	BookingWeek        string             `gorm:"column:booking_week;type:varchar(10)" json:"bookingWeek"`                   // 自動預約計數的週次，例如 2026-44
+	WeekBookings       int                `gorm:"column:week_bookings;not null;default:0" json:"weekBookings"`               // 該週已自動預約次數
+	LastBookedDate     *time.Time         `gorm:"column:last_booked_date;type:date" json:"lastBookedDate"`                   // 最後自動預約的日期，避免同一天重複預約
+	Paused             bool               `gorm:"column:paused;not null;default:false" json:"paused"`                        // 暫停時排程不檢查
+	NotifiedDate       *time.Time         `gorm:"column:notified_date;type:date" json:"notifiedDate"`                        // 最後通知的日期
+	NotifiedCourts     string             `gorm:"column:notified_courts;type:varchar(255)" json:"notifiedCourts"`            // 最後通知時可預約的場地，以逗號分隔，作為下次比對的基準
+	CreatedAt          time.Time          `gorm:"column:created_at;autoCreateTime" json:"createdAt" swaggerignore:"true"`
+	UpdatedAt          time.Time          `gorm:"column:updated_at;autoUpdateTime" json:"updatedAt" swaggerignore:"true"`
 }
 
 // TableName 設定資料表名稱
 func (Schedule) TableName() string {
 	return "schedule"
 }
+
+// CheckDate 取得下一個要檢查的日期，已無符合的日期時回傳 false
+func (s *Schedule) CheckDate(now time.Time) (time.Time, bool) {
+	today := types.DateOnly(now)
+
+	if s.Date != nil {
+		date := types.DateOnly(*s.Date)
+		if date.Before(today) {
+			return time.Time{}, false
+		}
+		return date, true
+	}
+
+	from := today
+	if s.StartDate != nil && types.DateOnly(*s.StartDate).After(from) {
+		from = types.DateOnly(*s.StartDate)
+	}
+
+	date := types.NextDateByWeekday(s.Weekday, from)
+	if s.EndDate != nil && date.After(types.DateOnly(*s.EndDate)) {
+		return time.Time{}, false
+	}
+	return date, true
+}
+
+// Expired 訂閱已無任何要檢查的日期
+func (s *Schedule) Expired(now time.Time) bool {
+	_, ok := s.CheckDate(now)
+	return !ok
+}
+
+// CanAutoBook 是否可為該日期自動預約
+func (s *Schedule) CanAutoBook(date time.Time) bool {
+	if !s.AutoBook {
+		return false
+	}
+	if s.LastBookedDate != nil && types.DateOnly(*s.LastBookedDate).Equal(types.DateOnly(date)) {
+		return false
+	}
+	if s.MaxBookingsPerWeek > 0 && s.BookingWeek == BookingWeek(date) && s.WeekBookings >= s.MaxBookingsPerWeek {
+		return false
+	}
+	return true
+}
+
+// Preference 訂閱的場地偏好
+func (s *Schedule) Preference() types.CourtPreference {
+	return types.ParseCourtPreference(s.CourtPreference)
+}
+
+// NotifiedCourtList 上次通知該日期時可預約的場地，日期不同或未曾通知時回傳 nil
+func (s *Schedule) NotifiedCourtList(date time.Time) []string {
+	if s.NotifiedDate == nil || !types.DateOnly(*s.NotifiedDate).Equal(types.DateOnly(date)) || s.NotifiedCourts == "" {
+		return nil
+	}
+	return strings.Split(s.NotifiedCourts, ",")
+}
+
+// BookingWeek 日期所屬的週次，用於計算每週預約次數
+func BookingWeek(date time.Time) string {
+	year, week := date.ISOWeek()
+	return fmt.Sprintf("%d-%02d", year, week)
+}
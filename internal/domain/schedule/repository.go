@@ -2,6 +2,7 @@ package schedule
 
 import (
 	"context"
+	"time"
 
 	"github.com/tian841224/crawler_sportcenter/internal/infrastructure/db"
 	"gorm.io/gorm"
@@ -13,7 +14,10 @@ type Repository interface {
 	GetByUserID(ctx context.Context, userID uint) ([]*Schedule, error)
 	GetAll(ctx context.Context) (*[]Schedule, error)
 	Update(ctx context.Context, schedule *Schedule) error
+	UpdateColumns(ctx context.Context, id uint, columns map[string]interface{}) error
 	Delete(ctx context.Context, id uint) error
+	MarkNotified(ctx context.Context, id uint, date time.Time, courts string) error
+	RecordAutoBooking(ctx context.Context, id uint, week string, count int, date time.Time) error
 }
 
 type ScheduleRepository struct {
@@ -36,7 +40,7 @@ func (r *ScheduleRepository) Create(ctx context.Context, schedule *Schedule) err
 func (r *ScheduleRepository) GetByID(ctx context.Context, id uint) (*Schedule, error) {
 	res := &Schedule{}
 	conn := (*r.db).GetConn().(*gorm.DB)
-	if err := conn.WithContext(ctx).First(res, id).Error; err != nil {
+	if err := conn.WithContext(ctx).Preload("TimeSlot").First(res, id).Error; err != nil {
 		return nil, err
 	}
 	return res, nil
@@ -45,7 +49,7 @@ func (r *ScheduleRepository) GetByID(ctx context.Context, id uint) (*Schedule, e
 func (r *ScheduleRepository) GetByUserID(ctx context.Context, userID uint) ([]*Schedule, error) {
 	var schedules []*Schedule
 	conn := (*r.db).GetConn().(*gorm.DB)
-	if err := conn.WithContext(ctx).Where("user_id = ?", userID).Find(&schedules).Error; err != nil {
+	if err := conn.WithContext(ctx).Preload("TimeSlot").Where("user_id = ?", userID).Order("id").Find(&schedules).Error; err != nil {
 		return nil, err
 	}
 	return schedules, nil
@@ -65,7 +69,30 @@ func (r *ScheduleRepository) Update(ctx context.Context, schedule *Schedule) err
 	return conn.WithContext(ctx).Save(schedule).Error
 }
 
+// UpdateColumns 只更新指定欄位，避免以舊資料覆蓋排程同時寫入的欄位
+func (r *ScheduleRepository) UpdateColumns(ctx context.Context, id uint, columns map[string]interface{}) error {
+	conn := (*r.db).GetConn().(*gorm.DB)
+	return conn.WithContext(ctx).Model(&Schedule{}).Where("id = ?", id).Updates(columns).Error
+}
+
 func (r *ScheduleRepository) Delete(ctx context.Context, id uint) error {
 	conn := (*r.db).GetConn().(*gorm.DB)
 	return conn.WithContext(ctx).Delete(&Schedule{}, id).Error
 }
+
+func (r *ScheduleRepository) MarkNotified(ctx context.Context, id uint, date time.Time, courts string) error {
+	conn := (*r.db).GetConn().(*gorm.DB)
+	return conn.WithContext(ctx).Model(&Schedule{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"notified_date":   date,
+		"notified_courts": courts,
+	}).Error
+}
+
+func (r *ScheduleRepository) RecordAutoBooking(ctx context.Context, id uint, week string, count int, date time.Time) error {
+	conn := (*r.db).GetConn().(*gorm.DB)
+	return conn.WithContext(ctx).Model(&Schedule{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"booking_week":     week,
+		"week_bookings":    count,
+		"last_booked_date": date,
+	}).Error
+}
@@ -3,15 +3,23 @@ package schedule
 import (
 	"context"
 	"errors"
+	"strings"
+	"time"
 )
 
+// ErrDuplicateSchedule 已訂閱相同場館與時段
+var ErrDuplicateSchedule = errors.New("已訂閱相同時段")
+
 type Service interface {
 	Create(ctx context.Context, schedule *Schedule) error
 	GetByID(ctx context.Context, id uint) (*Schedule, error)
 	GetByUserID(ctx context.Context, userID uint) ([]*Schedule, error)
 	GetAll(ctx context.Context) (*[]Schedule, error)
 	Update(ctx context.Context, schedule *Schedule) error
+	UpdateColumns(ctx context.Context, id uint, columns map[string]interface{}) error
 	Delete(ctx context.Context, id uint) error
+	MarkNotified(ctx context.Context, schedule *Schedule, date time.Time, courts []string) error
+	RecordAutoBooking(ctx context.Context, schedule *Schedule, date time.Time) error
 }
 
 type ScheduleService struct {
@@ -33,8 +41,10 @@ func (s *ScheduleService) Create(ctx context.Context, schedule *Schedule) error
 
 	for _, existingSchedule := range existingSchedules {
 		// 檢查是否已存在相同使用者在相同排程
-		if existingSchedule.Weekday == schedule.Weekday && existingSchedule.TimeSlotID == schedule.TimeSlotID {
-			return errors.New("已訂閱相同時段")
+		if existingSchedule.VenueID == schedule.VenueID && existingSchedule.Weekday == schedule.Weekday &&
+			existingSchedule.TimeSlotID != nil && schedule.TimeSlotID != nil && *existingSchedule.TimeSlotID == *schedule.TimeSlotID &&
+			sameDate(existingSchedule.Date, schedule.Date) {
+			return ErrDuplicateSchedule
 		}
 	}
 
@@ -58,6 +68,38 @@ func (s *ScheduleService) Update(ctx context.Context, schedule *Schedule) error
 	return s.repo.Update(ctx, schedule)
 }
 
+func (s *ScheduleService) UpdateColumns(ctx context.Context, id uint, columns map[string]interface{}) error {
+	return s.repo.UpdateColumns(ctx, id, columns)
+}
+
 func (s *ScheduleService) Delete(ctx context.Context, id uint) error {
 	return s.repo.Delete(ctx, id)
 }
+
+// MarkNotified 記錄已通知使用者的場地，作為下次比對的基準
+func (s *ScheduleService) MarkNotified(ctx context.Context, schedule *Schedule, date time.Time, courts []string) error {
+	schedule.NotifiedDate = &date
+	schedule.NotifiedCourts = strings.Join(courts, ",")
+	return s.repo.MarkNotified(ctx, schedule.ID, date, schedule.NotifiedCourts)
+}
+
+// RecordAutoBooking 記錄自動預約，跨週時重新計算次數
+func (s *ScheduleService) RecordAutoBooking(ctx context.Context, schedule *Schedule, date time.Time) error {
+	week := BookingWeek(date)
+	if schedule.BookingWeek != week {
+		schedule.BookingWeek = week
+		schedule.WeekBookings = 0
+	}
+	schedule.WeekBookings++
+	schedule.LastBookedDate = &date
+
+	return s.repo.RecordAutoBooking(ctx, schedule.ID, week, schedule.WeekBookings, date)
+}
+
+// 比較兩個指定日期是否相同，皆未指定也視為相同
+func sameDate(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Format("2006-01-02") == b.Format("2006-01-02")
+}
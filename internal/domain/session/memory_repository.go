@@ -0,0 +1,62 @@
+package session
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryRepository 以記憶體保存對話狀態，重啟後會遺失
+type MemoryRepository struct {
+	mutex    sync.RWMutex
+	sessions map[int64]Session
+}
+
+var _ Repository = (*MemoryRepository)(nil)
+
+func NewMemoryRepository() Repository {
+	return &MemoryRepository{
+		sessions: make(map[int64]Session),
+	}
+}
+
+func (r *MemoryRepository) GetByChatID(ctx context.Context, chatID int64) (*Session, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	session, exists := r.sessions[chatID]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return &session, nil
+}
+
+func (r *MemoryRepository) Save(ctx context.Context, session *Session) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.sessions[session.ChatID] = *session
+	return nil
+}
+
+func (r *MemoryRepository) Delete(ctx context.Context, chatID int64) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.sessions, chatID)
+	return nil
+}
+
+func (r *MemoryRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var count int64
+	for chatID, session := range r.sessions {
+		if now.After(session.ExpiresAt) {
+			delete(r.sessions, chatID)
+			count++
+		}
+	}
+	return count, nil
+}
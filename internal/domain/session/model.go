@@ -0,0 +1,19 @@
+package session
+
+import "time"
+
+// Session 每個聊天室的 Bot 對話狀態
+type Session struct {
+	ChatID     int64     `gorm:"primaryKey;column:chat_id;autoIncrement:false" json:"chatId"`
+	State      string    `gorm:"column:state;type:varchar(50)" json:"state"`      // 文字輸入流程狀態，例如 waiting_account
+	VenueID    string    `gorm:"column:venue_id;type:varchar(20)" json:"venueId"` // 輸入指定日期時選擇的場館
+	ScheduleID uint      `gorm:"column:schedule_id" json:"scheduleId"`            // 設定期限的訂閱
+	ExpiresAt  time.Time `gorm:"column:expires_at;index" json:"expiresAt"`
+	CreatedAt  time.Time `gorm:"column:created_at;autoCreateTime" json:"createdAt" swaggerignore:"true"`
+	UpdatedAt  time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updatedAt" swaggerignore:"true"`
+}
+
+// TableName 設定資料表名稱
+func (Session) TableName() string {
+	return "bot_session"
+}
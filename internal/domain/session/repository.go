@@ -0,0 +1,66 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/tian841224/crawler_sportcenter/internal/infrastructure/db"
+	"github.com/tian841224/crawler_sportcenter/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// ErrNotFound 找不到對話狀態
+var ErrNotFound = errors.New("session not found")
+
+type Repository interface {
+	GetByChatID(ctx context.Context, chatID int64) (*Session, error)
+	Save(ctx context.Context, session *Session) error
+	Delete(ctx context.Context, chatID int64) error
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+type SessionRepository struct {
+	db *db.DB
+}
+
+var _ Repository = (*SessionRepository)(nil)
+
+func NewSessionRepository(db *db.DB) Repository {
+	conn := (*db).GetConn().(*gorm.DB)
+	if err := conn.AutoMigrate(&Session{}); err != nil {
+		logger.Log.Error("資料庫遷移失敗", zap.Error(err))
+		return nil
+	}
+	return &SessionRepository{db: db}
+}
+
+func (r *SessionRepository) GetByChatID(ctx context.Context, chatID int64) (*Session, error) {
+	var session Session
+	conn := (*r.db).GetConn().(*gorm.DB)
+	if err := conn.WithContext(ctx).Where("chat_id = ?", chatID).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *SessionRepository) Save(ctx context.Context, session *Session) error {
+	conn := (*r.db).GetConn().(*gorm.DB)
+	return conn.WithContext(ctx).Save(session).Error
+}
+
+func (r *SessionRepository) Delete(ctx context.Context, chatID int64) error {
+	conn := (*r.db).GetConn().(*gorm.DB)
+	return conn.WithContext(ctx).Delete(&Session{}, chatID).Error
+}
+
+// DeleteExpired 刪除已過期的對話狀態，回傳刪除筆數
+func (r *SessionRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	conn := (*r.db).GetConn().(*gorm.DB)
+	result := conn.WithContext(ctx).Where("expires_at < ?", now).Delete(&Session{})
+	return result.RowsAffected, result.Error
+}
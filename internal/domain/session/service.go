@@ -0,0 +1,84 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/tian841224/crawler_sportcenter/pkg/logger"
+	"go.uber.org/zap"
+)
+
+type Service interface {
+	Get(ctx context.Context, chatID int64) (*Session, error)
+	Save(ctx context.Context, session *Session) error
+	Clear(ctx context.Context, chatID int64) error
+	StartCleanup(ctx context.Context, interval time.Duration)
+}
+
+type SessionService struct {
+	repo Repository
+	ttl  time.Duration
+}
+
+var _ Service = (*SessionService)(nil)
+
+func NewSessionService(repo Repository, ttl time.Duration) Service {
+	return &SessionService{repo: repo, ttl: ttl}
+}
+
+// Get 取得對話狀態，不存在或已過期時回傳新的空狀態
+func (s *SessionService) Get(ctx context.Context, chatID int64) (*Session, error) {
+	session, err := s.repo.GetByChatID(ctx, chatID)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return &Session{ChatID: chatID}, nil
+		}
+		return nil, err
+	}
+
+	if time.Now().After(session.ExpiresAt) {
+		if err := s.repo.Delete(ctx, chatID); err != nil {
+			return nil, err
+		}
+		return &Session{ChatID: chatID}, nil
+	}
+	return session, nil
+}
+
+// Save 儲存對話狀態並重新計算過期時間
+func (s *SessionService) Save(ctx context.Context, session *Session) error {
+	if session == nil || session.ChatID == 0 {
+		return errors.New("session 不能為空")
+	}
+	session.ExpiresAt = time.Now().Add(s.ttl)
+	return s.repo.Save(ctx, session)
+}
+
+func (s *SessionService) Clear(ctx context.Context, chatID int64) error {
+	return s.repo.Delete(ctx, chatID)
+}
+
+// StartCleanup 定期刪除已過期的對話狀態，避免未再讀取的狀態一直留在儲存空間
+func (s *SessionService) StartCleanup(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				count, err := s.repo.DeleteExpired(ctx, time.Now())
+				if err != nil {
+					logger.Log.Error("delete expired sessions", zap.Error(err))
+					continue
+				}
+				if count > 0 {
+					logger.Log.Info("清除過期對話狀態", zap.Int64("count", count))
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
@@ -3,13 +3,15 @@ package user
 import "time"
 
 type User struct {
-	ID                  uint      `gorm:"primaryKey;column:id;autoIncrement"`
-	AccountID           string    `gorm:"column:account_id;type:varchar(50);unique;not null"`
-	Status              bool      `gorm:"column:status;not null"`
-	SportCenterAccount  string    `gorm:"column:sport_center_account;type:varchar(50)"`
-	SportCenterPassword string    `gorm:"column:sport_center_password;type:varchar(50)"`
-	CreatedAt           time.Time `gorm:"column:created_at;autoCreateTime"`
-	UpdatedAt           time.Time `gorm:"column:updated_at;autoUpdateTime"`
+	ID                  uint       `gorm:"primaryKey;column:id;autoIncrement"`
+	AccountID           string     `gorm:"column:account_id;type:varchar(50);unique;not null"`
+	Status              bool       `gorm:"column:status;not null"`
+	SportCenterAccount  string     `gorm:"column:sport_center_account;type:varchar(50)"`
+	SportCenterPassword string     `gorm:"column:sport_center_password;type:varchar(255)"` // 加密後儲存
+	CourtPreference     string     `gorm:"column:court_preference;type:varchar(255)"`      // 場地偏好，例如 羽球A場>羽球C場 !羽球F場
+	LastNotifiedAt      *time.Time `gorm:"column:last_notified_at"`                        // 最後收到場地通知的時間，用於冷卻
+	CreatedAt           time.Time  `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt           time.Time  `gorm:"column:updated_at;autoUpdateTime"`
 }
 
 func (User) TableName() string {
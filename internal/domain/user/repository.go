@@ -2,9 +2,11 @@ package user
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/tian841224/crawler_sportcenter/internal/infrastructure/db"
 	"github.com/tian841224/crawler_sportcenter/pkg/logger"
+	"github.com/tian841224/crawler_sportcenter/pkg/secret"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
@@ -15,24 +17,36 @@ type Repository interface {
 	GetByAccountID(ctx context.Context, accountID string) (*User, error)
 	Update(ctx context.Context, id uint, updates map[string]interface{}) error
 	Delete(ctx context.Context, id uint) error
+	GetSportCenterCredential(ctx context.Context, accountID string) (string, string, error)
+	ReencryptPasswords(ctx context.Context) (int, error)
 }
 
 type UserRepository struct {
-	db *db.DB
+	db  *db.DB
+	box *secret.Box
 }
 
 var _ Repository = (*UserRepository)(nil)
 
-func NewUserRepository(db *db.DB) Repository {
+func NewUserRepository(db *db.DB, box *secret.Box) Repository {
 	conn := (*db).GetConn().(*gorm.DB)
 	if err := conn.AutoMigrate(&User{}); err != nil {
 		logger.Log.Error("資料庫遷移失敗", zap.Error(err))
 		return nil
 	}
-	return &UserRepository{db: db}
+	return &UserRepository{db: db, box: box}
 }
 
 func (r *UserRepository) Create(ctx context.Context, user *User) error {
+	// 密碼皆來自使用者輸入，一律視為明文加密，只有 ReencryptPasswords 直接寫入密文
+	if user.SportCenterPassword != "" {
+		encrypted, err := r.box.Encrypt(user.SportCenterPassword)
+		if err != nil {
+			return fmt.Errorf("encrypt password: %w", err)
+		}
+		user.SportCenterPassword = encrypted
+	}
+
 	conn := (*r.db).GetConn().(*gorm.DB)
 	return conn.WithContext(ctx).Create(user).Error
 }
@@ -59,6 +73,15 @@ func (r *UserRepository) GetByAccountID(ctx context.Context, accountID string) (
 }
 
 func (r *UserRepository) Update(ctx context.Context, id uint, updates map[string]interface{}) error {
+	// 密碼一律視為明文加密後才寫入，即使內容看起來已是密文格式
+	if password, ok := updates["sport_center_password"].(string); ok && password != "" {
+		encrypted, err := r.box.Encrypt(password)
+		if err != nil {
+			return fmt.Errorf("encrypt password: %w", err)
+		}
+		updates["sport_center_password"] = encrypted
+	}
+
 	conn := (*r.db).GetConn().(*gorm.DB)
 	return conn.WithContext(ctx).Model(&User{}).Where("id =?", id).Updates(updates).Error
 }
@@ -67,3 +90,50 @@ func (r *UserRepository) Delete(ctx context.Context, id uint) error {
 	conn := (*r.db).GetConn().(*gorm.DB)
 	return conn.WithContext(ctx).Delete(&User{}, id).Error
 }
+
+// GetSportCenterCredential 取得解密後的運動中心帳密，僅供爬蟲登入使用
+func (r *UserRepository) GetSportCenterCredential(ctx context.Context, accountID string) (string, string, error) {
+	user, err := r.GetByAccountID(ctx, accountID)
+	if err != nil {
+		return "", "", err
+	}
+
+	password, err := r.box.Decrypt(user.SportCenterPassword)
+	if err != nil {
+		return "", "", fmt.Errorf("decrypt password: %w", err)
+	}
+	return user.SportCenterAccount, password, nil
+}
+
+// ReencryptPasswords 將明文或舊金鑰加密的密碼以目前金鑰重新加密，回傳更新筆數
+func (r *UserRepository) ReencryptPasswords(ctx context.Context) (int, error) {
+	users, err := r.GetAll(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	conn := (*r.db).GetConn().(*gorm.DB)
+	for _, user := range users {
+		if user.SportCenterPassword == "" || !r.box.NeedsRotation(user.SportCenterPassword) {
+			continue
+		}
+
+		password, err := r.box.Decrypt(user.SportCenterPassword)
+		if err != nil {
+			return count, fmt.Errorf("decrypt password of user %d: %w", user.ID, err)
+		}
+
+		encrypted, err := r.box.Encrypt(password)
+		if err != nil {
+			return count, fmt.Errorf("encrypt password of user %d: %w", user.ID, err)
+		}
+
+		if err := conn.WithContext(ctx).Model(&User{}).Where("id = ?", user.ID).
+			Update("sport_center_password", encrypted).Error; err != nil {
+			return count, fmt.Errorf("update password of user %d: %w", user.ID, err)
+		}
+		count++
+	}
+	return count, nil
+}
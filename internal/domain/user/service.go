@@ -13,6 +13,8 @@ type Service interface {
 	GetByAccountID(ctx context.Context, accountID string) (*User, error)
 	Update(ctx context.Context, id uint, updates map[string]interface{}) error
 	Delete(ctx context.Context, id uint) error
+	GetSportCenterCredential(ctx context.Context, accountID string) (string, string, error)
+	ReencryptPasswords(ctx context.Context) (int, error)
 }
 
 type UserService struct {
@@ -70,3 +72,14 @@ func (s *UserService) Delete(ctx context.Context, id uint) error {
 	}
 	return s.repo.Delete(ctx, id)
 }
+
+func (s *UserService) GetSportCenterCredential(ctx context.Context, accountID string) (string, string, error) {
+	if accountID == "" {
+		return "", "", errors.New("accountID 不能為空")
+	}
+	return s.repo.GetSportCenterCredential(ctx, accountID)
+}
+
+func (s *UserService) ReencryptPasswords(ctx context.Context) (int, error) {
+	return s.repo.ReencryptPasswords(ctx)
+}
@@ -0,0 +1,123 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/tian841224/crawler_sportcenter/internal/crawler"
+	"github.com/tian841224/crawler_sportcenter/internal/domain/schedule"
+	"github.com/tian841224/crawler_sportcenter/internal/domain/user"
+	"github.com/tian841224/crawler_sportcenter/internal/types"
+	"github.com/tian841224/crawler_sportcenter/pkg/config"
+	"github.com/tian841224/crawler_sportcenter/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// SessionKeepAlive 定期為有訂閱的使用者保持運動中心的登入狀態
+// 避免排程查詢或自動預約時才發現網站登入已逾時
+type SessionKeepAlive struct {
+	providers      *crawler.ProviderRegistry
+	schedule       schedule.Service
+	user           user.Service
+	adminAccountID string // 排程優先以管理員帳號查詢，一併保持
+	interval       time.Duration
+	stopChan       chan struct{}
+}
+
+var _ SchedulerInterface = (*SessionKeepAlive)(nil)
+
+func NewSessionKeepAlive(providers *crawler.ProviderRegistry, schedule schedule.Service, user user.Service, cfg config.Config) *SessionKeepAlive {
+	return &SessionKeepAlive{
+		providers:      providers,
+		schedule:       schedule,
+		user:           user,
+		adminAccountID: cfg.AdminAccountID,
+		interval:       time.Duration(cfg.KeepAliveInterval) * time.Minute,
+		stopChan:       make(chan struct{}),
+	}
+}
+
+// 啟動定時保持登入
+func (k *SessionKeepAlive) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(k.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				k.keepAll(ctx)
+			case <-k.stopChan:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// 停止保持登入
+func (k *SessionKeepAlive) Stop() {
+	close(k.stopChan)
+}
+
+func (k *SessionKeepAlive) keepAll(ctx context.Context) {
+	for _, provider := range k.providers.List() {
+		keeper, ok := provider.(crawler.SessionKeeper)
+		if !ok {
+			continue
+		}
+
+		tags, err := k.subscribedTags(ctx, provider.ID())
+		if err != nil {
+			logger.Log.Error("get subscribed users", zap.String("venueID", string(provider.ID())), zap.Error(err))
+			continue
+		}
+
+		for _, tag := range tags {
+			if err := keeper.KeepAlive(tag); err != nil && !errors.Is(err, crawler.ErrCredentialNotSet) {
+				logger.Log.Warn("保持登入失敗", zap.String("venueID", string(provider.ID())), zap.String("tag", tag), zap.Error(err))
+			}
+		}
+	}
+}
+
+// 場館有啟用中的訂閱時，取得管理員與訂閱者的帳號
+func (k *SessionKeepAlive) subscribedTags(ctx context.Context, venueID types.VenueID) ([]string, error) {
+	scheduleList, err := k.schedule.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var tags []string
+	subscribed := false
+	seen := make(map[uint]struct{})
+	for _, subs := range *scheduleList {
+		if types.VenueID(subs.VenueID) != venueID || subs.Paused {
+			continue
+		}
+		if _, exists := seen[subs.UserID]; exists {
+			continue
+		}
+		seen[subs.UserID] = struct{}{}
+
+		u, err := k.user.GetByID(ctx, subs.UserID)
+		if err != nil {
+			logger.Log.Error("get user", zap.Uint("userID", subs.UserID), zap.Error(err))
+			continue
+		}
+		if !u.Status {
+			continue
+		}
+		subscribed = true
+		if u.AccountID != k.adminAccountID {
+			tags = append(tags, u.AccountID)
+		}
+	}
+
+	if subscribed && k.adminAccountID != "" {
+		tags = append([]string{k.adminAccountID}, tags...)
+	}
+	return tags, nil
+}
@@ -0,0 +1,255 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	tgbot "github.com/tian841224/crawler_sportcenter/internal/bot/tg_bot"
+	"github.com/tian841224/crawler_sportcenter/internal/clock"
+	"github.com/tian841224/crawler_sportcenter/internal/crawler"
+	"github.com/tian841224/crawler_sportcenter/internal/domain/booking"
+	"github.com/tian841224/crawler_sportcenter/internal/domain/user"
+	"github.com/tian841224/crawler_sportcenter/internal/types"
+	"github.com/tian841224/crawler_sportcenter/pkg/config"
+	"github.com/tian841224/crawler_sportcenter/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// PaymentWatcher 定期查詢待付款預約的付款狀態，並在繳費期限前提醒
+type PaymentWatcher struct {
+	providers *crawler.ProviderRegistry
+	booking   booking.Service
+	user      user.Service
+	tgBot     tgbot.TGBotInterface
+	clock     clock.Clock
+	interval  time.Duration
+	reminders []time.Duration // 由長到短排序
+	stopChan  chan struct{}
+}
+
+var _ SchedulerInterface = (*PaymentWatcher)(nil)
+
+func NewPaymentWatcher(providers *crawler.ProviderRegistry, booking booking.Service, user user.Service, tgBot tgbot.TGBotInterface, clk clock.Clock, cfg config.Config) *PaymentWatcher {
+	reminders := make([]time.Duration, 0, len(cfg.PaymentReminders))
+	for _, minutes := range cfg.PaymentReminders {
+		reminders = append(reminders, time.Duration(minutes)*time.Minute)
+	}
+	sort.Slice(reminders, func(i, j int) bool { return reminders[i] > reminders[j] })
+
+	return &PaymentWatcher{
+		providers: providers,
+		booking:   booking,
+		user:      user,
+		tgBot:     tgBot,
+		clock:     clk,
+		interval:  time.Duration(cfg.PaymentCheckInterval) * time.Minute,
+		reminders: reminders,
+		stopChan:  make(chan struct{}),
+	}
+}
+
+// 啟動定時檢查
+func (w *PaymentWatcher) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				w.checkAll(ctx)
+			case <-w.stopChan:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// 停止檢查
+func (w *PaymentWatcher) Stop() {
+	close(w.stopChan)
+}
+
+// 依使用者與場館分組查詢訂單，每組只查詢一次
+func (w *PaymentWatcher) checkAll(ctx context.Context) {
+	pending, err := w.booking.GetByStatus(ctx, booking.StatusPendingPayment)
+	if err != nil {
+		logger.Log.Error("get pending bookings", zap.Error(err))
+		return
+	}
+
+	groups := make(map[string][]*booking.Booking)
+	var keys []string
+	for _, b := range pending {
+		key := fmt.Sprintf("%d-%s", b.UserID, b.VenueID)
+		if _, exists := groups[key]; !exists {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], b)
+	}
+
+	now := w.clock.Now()
+	for _, key := range keys {
+		bookings := groups[key]
+
+		userObj, err := w.user.GetByID(ctx, bookings[0].UserID)
+		if err != nil {
+			logger.Log.Error("get user", zap.Uint("userID", bookings[0].UserID), zap.Error(err))
+			continue
+		}
+
+		provider, err := w.providers.Get(types.VenueID(bookings[0].VenueID))
+		if err != nil {
+			logger.Log.Error("get provider", zap.String("venueID", bookings[0].VenueID), zap.Error(err))
+			continue
+		}
+
+		// 不支援查詢訂單的場館只依已知的期限提醒
+		orders, err := provider.GetOrders(userObj.AccountID)
+		if err != nil && !errors.Is(err, crawler.ErrNotSupported) {
+			logger.Log.Error("get orders", zap.String("venueID", bookings[0].VenueID), zap.Error(err))
+			continue
+		}
+
+		for _, b := range bookings {
+			if err == nil {
+				w.apply(ctx, userObj, provider, b, matchOrder(orders, b), now)
+			}
+			if b.Status == booking.StatusPendingPayment {
+				w.remind(ctx, userObj, provider, b, now)
+			}
+		}
+	}
+}
+
+// 依訂單狀態更新預約紀錄
+func (w *PaymentWatcher) apply(ctx context.Context, userObj *user.User, provider crawler.SportCenterProvider, b *booking.Booking, order *types.Order, now time.Time) {
+	if order == nil {
+		// 訂單已不在列表上且已過繳費期限，視為逾期取消
+		if b.PaymentDeadline != nil && now.After(*b.PaymentDeadline) {
+			w.expire(ctx, userObj, provider, b)
+		}
+		return
+	}
+
+	switch order.Status {
+	case types.OrderUnknown:
+		// 無法辨識狀態時不更新，等下次檢查
+		logger.Log.Warn("無法辨識訂單狀態", zap.Uint("bookingID", b.ID), zap.String("orderID", order.OrderID))
+	case types.OrderPaid:
+		w.updateStatus(ctx, b, booking.StatusPaid)
+	case types.OrderExpired:
+		w.expire(ctx, userObj, provider, b)
+	case types.OrderCancelled:
+		if b.PaymentDeadline != nil && now.After(*b.PaymentDeadline) {
+			w.expire(ctx, userObj, provider, b)
+			return
+		}
+		w.updateStatus(ctx, b, booking.StatusCancelled)
+	case types.OrderUnpaid:
+		changed := false
+		if order.OrderID != "" && order.OrderID != b.OrderID {
+			b.OrderID = order.OrderID
+			changed = true
+		}
+		if !order.PaymentDeadline.IsZero() && (b.PaymentDeadline == nil || !b.PaymentDeadline.Equal(order.PaymentDeadline)) {
+			deadline := order.PaymentDeadline
+			b.PaymentDeadline = &deadline
+			changed = true
+		}
+		if changed {
+			if err := w.booking.Update(ctx, b); err != nil {
+				logger.Log.Error("update booking", zap.Uint("bookingID", b.ID), zap.Error(err))
+			}
+		}
+	}
+}
+
+// 逾期未付款，網站已自動取消
+func (w *PaymentWatcher) expire(ctx context.Context, userObj *user.User, provider crawler.SportCenterProvider, b *booking.Booking) {
+	if !w.updateStatus(ctx, b, booking.StatusExpired) {
+		return
+	}
+	w.sendToUser(userObj, fmt.Sprintf("%s 因逾期未付款已被取消", bookingText(provider, b)))
+}
+
+func (w *PaymentWatcher) updateStatus(ctx context.Context, b *booking.Booking, status booking.Status) bool {
+	if err := w.booking.UpdateStatus(ctx, b.ID, status); err != nil {
+		logger.Log.Error("update booking status", zap.Uint("bookingID", b.ID), zap.Error(err))
+		return false
+	}
+	b.Status = status
+	logger.Log.Info("預約狀態更新", zap.Uint("bookingID", b.ID), zap.String("status", string(status)))
+	return true
+}
+
+// 到達提醒時間時提醒付款，同時到達多個提醒時間只提醒一次
+func (w *PaymentWatcher) remind(ctx context.Context, userObj *user.User, provider crawler.SportCenterProvider, b *booking.Booking, now time.Time) {
+	if b.PaymentDeadline == nil {
+		return
+	}
+	remaining := b.PaymentDeadline.Sub(now)
+	if remaining <= 0 {
+		return
+	}
+
+	var due time.Duration
+	for _, reminder := range w.reminders {
+		if remaining <= reminder {
+			due = reminder
+		}
+	}
+	if due == 0 || (b.RemindedBefore > 0 && int(due/time.Minute) >= b.RemindedBefore) {
+		return
+	}
+
+	message := fmt.Sprintf("提醒：%s 尚未付款，請於 %s 前完成付款：\n%s",
+		bookingText(provider, b), b.PaymentDeadline.Format("2006-01-02 15:04"), provider.GetPaymentURL())
+	if !w.sendToUser(userObj, message) {
+		return
+	}
+
+	b.RemindedBefore = int(due / time.Minute)
+	if err := w.booking.Update(ctx, b); err != nil {
+		logger.Log.Error("update booking", zap.Uint("bookingID", b.ID), zap.Error(err))
+	}
+}
+
+func (w *PaymentWatcher) sendToUser(user *user.User, message string) bool {
+	accountID, err := strconv.ParseInt(user.AccountID, 10, 64)
+	if err != nil {
+		logger.Log.Error("invalid AccountID", zap.String("AccountID", user.AccountID), zap.Error(err))
+		return false
+	}
+	w.tgBot.SendMessage(accountID, message)
+	return true
+}
+
+// 找出與預約相符的訂單
+func matchOrder(orders []types.Order, b *booking.Booking) *types.Order {
+	target := b.Order()
+	for i := range orders {
+		if orders[i].SameAs(target) {
+			return &orders[i]
+		}
+	}
+	return nil
+}
+
+// 預約顯示文字，例如：南屯運動中心 2026-11-03 19:00-20:00 羽球A場
+func bookingText(provider crawler.SportCenterProvider, b *booking.Booking) string {
+	text := fmt.Sprintf("%s %s", provider.Name(), b.Date.Format(types.DateLayout))
+	if !b.StartTime.IsZero() {
+		text += fmt.Sprintf(" %s-%s", b.StartTime.Format("15:04"), b.EndTime.Format("15:04"))
+	}
+	if b.CourtName != "" {
+		text += " " + b.CourtName
+	}
+	return text
+}
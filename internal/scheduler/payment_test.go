@@ -0,0 +1,47 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tian841224/crawler_sportcenter/internal/domain/booking"
+	"github.com/tian841224/crawler_sportcenter/internal/domain/user"
+	"github.com/tian841224/crawler_sportcenter/internal/types"
+)
+
+type fakePaymentBookings struct {
+	booking.Service
+	statuses []booking.Status
+	updates  int
+}
+
+func (s *fakePaymentBookings) UpdateStatus(ctx context.Context, id uint, status booking.Status) error {
+	s.statuses = append(s.statuses, status)
+	return nil
+}
+
+func (s *fakePaymentBookings) Update(ctx context.Context, b *booking.Booking) error {
+	s.updates++
+	return nil
+}
+
+func TestPaymentApplySkipsUnknownStatus(t *testing.T) {
+	bookings := &fakePaymentBookings{}
+	bot := &fakeBot{}
+	w := &PaymentWatcher{booking: bookings, tgBot: bot}
+
+	now := time.Date(2026, 11, 3, 12, 0, 0, 0, time.Local)
+	deadline := now.Add(-time.Hour)
+	b := &booking.Booking{ID: 1, Status: booking.StatusPendingPayment, OrderID: "A1130001", PaymentDeadline: &deadline}
+	order := &types.Order{OrderID: "A1130002", Status: types.OrderUnknown, PaymentDeadline: now.Add(time.Hour)}
+
+	w.apply(context.Background(), &user.User{AccountID: "100"}, &fakeProvider{t: t}, b, order, now)
+
+	if len(bookings.statuses) != 0 || bookings.updates != 0 || len(bot.sent) != 0 {
+		t.Fatalf("statuses = %v, updates = %d, sent = %+v, want the booking untouched", bookings.statuses, bookings.updates, bot.sent)
+	}
+	if b.Status != booking.StatusPendingPayment || b.OrderID != "A1130001" || !b.PaymentDeadline.Equal(deadline) {
+		t.Errorf("booking = %+v, want it unchanged", b)
+	}
+}
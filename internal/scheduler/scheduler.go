@@ -2,27 +2,42 @@ package scheduler
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	tgbot "github.com/tian841224/crawler_sportcenter/internal/bot/tg_bot"
+	"github.com/tian841224/crawler_sportcenter/internal/clock"
 	"github.com/tian841224/crawler_sportcenter/internal/crawler"
+	"github.com/tian841224/crawler_sportcenter/internal/domain/availability"
+	"github.com/tian841224/crawler_sportcenter/internal/domain/booking"
 	"github.com/tian841224/crawler_sportcenter/internal/domain/schedule"
 	"github.com/tian841224/crawler_sportcenter/internal/domain/user"
+	"github.com/tian841224/crawler_sportcenter/internal/types"
+	"github.com/tian841224/crawler_sportcenter/pkg/config"
 	"github.com/tian841224/crawler_sportcenter/pkg/logger"
 	"go.uber.org/zap"
 )
 
 type SchedulerService struct {
-	nantunSportCenter crawler.NantunSportCenterBotInterface
-	schedule          schedule.Service
-	user              user.Service
-	tgBot             tgbot.TGBotInterface
-	mutex             sync.RWMutex
-	stopChan          chan struct{}
+	providers      *crawler.ProviderRegistry
+	schedule       schedule.Service
+	user           user.Service
+	availability   availability.Service
+	booking        booking.Service
+	tgBot          tgbot.TGBotInterface
+	clock          clock.Clock        // 以網站時間判斷日期與冷卻
+	notifyCooldown time.Duration      // 同一使用者兩次通知的最短間隔
+	notifyOnLost   bool               // 場地被預約走時是否通知
+	adminAccountID string             // 優先用於查詢的帳號
+	autoBookTried  map[uint]time.Time // 各訂閱已嘗試自動預約的日期，沒有新空出的場地時不再重試
+	mutex          sync.RWMutex
+	checkChan      chan struct{} // 要求立即檢查所有訂閱
+	stopChan       chan struct{}
 }
 
 type SchedulerInterface interface {
@@ -30,15 +45,26 @@ type SchedulerInterface interface {
 	Stop()
 }
 
-var _ SchedulerInterface = (*SchedulerService)(nil)
+var (
+	_ SchedulerInterface         = (*SchedulerService)(nil)
+	_ tgbot.CourtReleaseListener = (*SchedulerService)(nil)
+)
 
-func NewSchedulerService(nantunSportCenter crawler.NantunSportCenterBotInterface, schedule schedule.Service, user user.Service, tgBot tgbot.TGBotInterface) *SchedulerService {
+func NewSchedulerService(providers *crawler.ProviderRegistry, schedule schedule.Service, user user.Service, availability availability.Service, booking booking.Service, tgBot tgbot.TGBotInterface, clk clock.Clock, cfg config.Config) *SchedulerService {
 	return &SchedulerService{
-		nantunSportCenter: nantunSportCenter,
-		tgBot:             tgBot,
-		schedule:          schedule,
-		user:              user,
-		stopChan:          make(chan struct{}),
+		providers:      providers,
+		tgBot:          tgBot,
+		schedule:       schedule,
+		user:           user,
+		availability:   availability,
+		booking:        booking,
+		clock:          clk,
+		notifyCooldown: time.Duration(cfg.NotifyCooldown) * time.Minute,
+		notifyOnLost:   cfg.NotifyOnLost,
+		adminAccountID: cfg.AdminAccountID,
+		autoBookTried:  make(map[uint]time.Time),
+		checkChan:      make(chan struct{}, 1),
+		stopChan:       make(chan struct{}),
 	}
 }
 
@@ -52,6 +78,8 @@ func (s *SchedulerService) Start(ctx context.Context) {
 			select {
 			case <-ticker.C:
 				s.checkAllSubscriptions(ctx)
+			case <-s.checkChan:
+				s.checkAllSubscriptions(ctx)
 			case <-s.stopChan:
 				return
 			}
@@ -64,6 +92,16 @@ func (s *SchedulerService) Stop() {
 	close(s.stopChan)
 }
 
+// CourtReleased 有預約被取消時立即檢查訂閱，不等下一次定時檢查
+func (s *SchedulerService) CourtReleased(venueID types.VenueID, date time.Time) {
+	logger.Log.Info("場地已釋出，重新檢查訂閱", zap.String("venueID", string(venueID)), zap.String("date", date.Format(types.DateLayout)))
+	select {
+	case s.checkChan <- struct{}{}:
+	default:
+		// 已有待執行的檢查
+	}
+}
+
 // 檢查所有訂閱
 func (s *SchedulerService) checkAllSubscriptions(ctx context.Context) error {
 	s.mutex.RLock()
@@ -75,7 +113,11 @@ func (s *SchedulerService) checkAllSubscriptions(ctx context.Context) error {
 	}
 
 	sort.Slice(*scheduleList, func(i, j int) bool {
-		// 先比星期
+		// 先比場館
+		if (*scheduleList)[i].VenueID != (*scheduleList)[j].VenueID {
+			return (*scheduleList)[i].VenueID < (*scheduleList)[j].VenueID
+		}
+		// 再比星期
 		if (*scheduleList)[i].Weekday != (*scheduleList)[j].Weekday {
 			return (*scheduleList)[i].Weekday < (*scheduleList)[j].Weekday
 		}
@@ -86,63 +128,383 @@ func (s *SchedulerService) checkAllSubscriptions(ctx context.Context) error {
 		return (*scheduleList)[i].TimeSlot.StartTime.Before((*scheduleList)[j].TimeSlot.StartTime)
 	})
 
-	currentWeekday := time.Now().Weekday()
-	currentTime := time.Now().Hour()
-	availableTimeSlotsLength := 0
+	now := s.clock.Now()
+
+	// 清除已過日期的場地狀態
+	if err := s.availability.DeleteBefore(ctx, now.Format("2006-01-02")); err != nil {
+		logger.Log.Error("checkAllSubscriptions", zap.Error(err))
+	}
+	for id, date := range s.autoBookTried {
+		if date.Before(types.DateOnly(now)) {
+			delete(s.autoBookTried, id)
+		}
+	}
+
+	// 同場館同日期同時段只查詢一次，再將結果發送給所有訂閱者
+	bookableDates := make(map[string][]time.Time)
+	for _, group := range s.groupSubscriptions(ctx, *scheduleList, now) {
+		if !s.isBookable(group, bookableDates, now) {
+			logger.Log.Debug("日期尚未開放預約", zap.String("slot", group.key))
+			continue
+		}
+
+		availableTimeSlots, change, err := s.checkAvailability(ctx, group)
+		if err != nil {
+			logger.Log.Error("checkAllSubscriptions", zap.String("slot", group.key), zap.Error(err))
+			continue
+		}
+
+		for _, sub := range group.subscribers {
+			// 自動預約成功時不再發送空場地通知
+			if s.shouldAutoBook(group, sub, availableTimeSlots, change) && s.autoBook(ctx, group, sub, availableTimeSlots) {
+				continue
+			}
+			s.notify(ctx, group, sub, availableTimeSlots)
+		}
+		logger.Log.Debug("checkAllSubscriptions", zap.String("slot", group.key), zap.Int("subscribers", len(group.subscribers)))
+	}
 
-	for _, subs := range *scheduleList {
+	return nil
+}
+
+// 訂閱者
+type subscriber struct {
+	schedule schedule.Schedule
+	user     *user.User
+}
+
+// 同場館、同日期、同時段的訂閱
+type slotGroup struct {
+	key         string
+	venueID     string
+	date        time.Time
+	timeSlotID  uint
+	subscribers []subscriber
+}
+
+// 依場館、日期、時段分組，略過暫停的訂閱與停用的使用者，並刪除已到期的訂閱
+func (s *SchedulerService) groupSubscriptions(ctx context.Context, scheduleList []schedule.Schedule, now time.Time) []*slotGroup {
+	var groups []*slotGroup
+	groupMap := make(map[string]*slotGroup)
+	userMap := make(map[uint]*user.User)
+
+	for _, subs := range scheduleList {
 		// 檢查 TimeSlot 是否為空值
-		if subs.TimeSlot == nil {
+		if subs.TimeSlot == nil || subs.TimeSlotID == nil {
 			logger.Log.Warn("TimeSlot is nil", zap.Uint("scheduleID", subs.ID))
 			continue
 		}
 
-		// 查詢的時間一樣直接通知使用者
-		if subs.Weekday != currentWeekday || subs.TimeSlot.StartTime.Hour() != currentTime {
-			// 檢查是否有可用場地
+		u, exists := userMap[subs.UserID]
+		if !exists {
 			var err error
-			dayMap := map[string]string{
-				"0": "日", "1": "一", "2": "二",
-				"3": "三", "4": "四", "5": "五", "6": "六",
-			}
-
-			weekday := dayMap[strconv.Itoa(int(subs.Weekday))]
-			availableTimeSlots, err := s.nantunSportCenter.GetAvailableTimeSlotsForSchedule(weekday, int(*subs.TimeSlotID), strconv.Itoa(int(subs.UserID)))
-			if err != nil {
-				logger.Log.Error("checkAllSubscriptions", zap.Error(err))
+			if u, err = s.user.GetByID(ctx, subs.UserID); err != nil {
+				logger.Log.Error("groupSubscriptions", zap.Uint("userID", subs.UserID), zap.Error(err))
 				continue
 			}
-			availableTimeSlotsLength = len(availableTimeSlots)
+			userMap[subs.UserID] = u
 		}
 
-		// 沒有可用場地直接跳過
-		if availableTimeSlotsLength == 0 {
+		date, ok := subs.CheckDate(now)
+		if !ok {
+			s.expire(ctx, subs, u)
 			continue
 		}
 
-		// 如果有可用場地，通知使用者
-		message := fmt.Sprintf("星期 %s 時段 %d:00-%d:00 有可用場地",
-			subs.Weekday,
-			subs.TimeSlot.StartTime.Hour(),
-			subs.TimeSlot.EndTime.Hour())
-
-		user, err := s.user.GetByID(ctx, subs.UserID)
-		if err != nil {
-			logger.Log.Error("checkAllSubscriptions", zap.Error(err))
+		if !u.Status || subs.Paused {
 			continue
 		}
-		// TODO: 修改成TG帳號
-		accountID, err := strconv.ParseInt(user.AccountID, 10, 64)
-		if err != nil {
-			logger.Log.Error("invalid AccountID", zap.String("AccountID", user.AccountID), zap.Error(err))
+
+		key := fmt.Sprintf("%s-%s-%d", subs.VenueID, date.Format("2006-01-02"), *subs.TimeSlotID)
+		group, exists := groupMap[key]
+		if !exists {
+			group = &slotGroup{
+				key:        key,
+				venueID:    subs.VenueID,
+				date:       date,
+				timeSlotID: *subs.TimeSlotID,
+			}
+			groupMap[key] = group
+			groups = append(groups, group)
+		}
+		group.subscribers = append(group.subscribers, subscriber{schedule: subs, user: u})
+	}
+
+	return groups
+}
+
+// 查詢用的帳號：優先使用管理員，失敗時依序改用訂閱者帳號
+func (s *SchedulerService) crawlTags(group *slotGroup) []string {
+	var tags []string
+	seen := make(map[string]struct{})
+	add := func(tag string) {
+		if tag == "" {
+			return
+		}
+		if _, exists := seen[tag]; exists {
+			return
+		}
+		seen[tag] = struct{}{}
+		tags = append(tags, tag)
+	}
+
+	add(s.adminAccountID)
+	for _, sub := range group.subscribers {
+		add(sub.user.AccountID)
+	}
+	return tags
+}
+
+// 查詢場地並與上次狀態比對
+func (s *SchedulerService) checkAvailability(ctx context.Context, group *slotGroup) ([]types.Slot, *availability.Change, error) {
+	provider, err := s.providers.Get(types.VenueID(group.venueID))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tags := s.crawlTags(group)
+	if len(tags) == 0 {
+		return nil, nil, fmt.Errorf("沒有可用於查詢的帳號: %s", group.key)
+	}
+
+	var availableTimeSlots []types.Slot
+	for _, tag := range tags {
+		availableTimeSlots, err = provider.GetAvailableTimeSlotsForSchedule(group.date, int(group.timeSlotID), tag)
+		if err == nil {
+			break
+		}
+		logger.Log.Warn("查詢場地失敗，改用下一個帳號", zap.String("slot", group.key), zap.String("tag", tag), zap.Error(err))
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	courts := make([]string, 0, len(availableTimeSlots))
+	for _, slot := range availableTimeSlots {
+		courts = append(courts, slot.CourtName)
+	}
+
+	change, err := s.availability.Update(ctx, group.venueID, group.date.Format("2006-01-02"), group.timeSlotID, courts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return availableTimeSlots, change, nil
+}
+
+// 有新空出的場地，或訂閱者尚未嘗試過該日期時才自動預約，避免每輪重複預約同一批場地
+func (s *SchedulerService) shouldAutoBook(group *slotGroup, sub subscriber, slots []types.Slot, change *availability.Change) bool {
+	if len(slots) == 0 || !sub.schedule.CanAutoBook(group.date) {
+		return false
+	}
+	if len(change.Gained) > 0 {
+		return true
+	}
+	tried, exists := s.autoBookTried[sub.schedule.ID]
+	return !exists || !tried.Equal(group.date)
+}
+
+// 以本輪查到的場地及訂閱者自己的帳號自動預約，成功時回傳 true
+func (s *SchedulerService) autoBook(ctx context.Context, group *slotGroup, sub subscriber, availableTimeSlots []types.Slot) bool {
+	provider, err := s.providers.Get(types.VenueID(group.venueID))
+	if err != nil {
+		logger.Log.Error("autoBook", zap.Error(err))
+		return false
+	}
+	s.autoBookTried[sub.schedule.ID] = group.date
+
+	candidates := autoBookCandidates(sub, availableTimeSlots)
+	if len(candidates) == 0 {
+		logger.Log.Info("沒有符合自動預約條件的場地", zap.Uint("scheduleID", sub.schedule.ID))
+		return false
+	}
+
+	// 預約參數已包含場地與日期，直接以訂閱者的帳號預約，不需重新查詢
+	bookedSlot, err := provider.BookCourt(candidates, sub.preference(), sub.user.AccountID)
+	if err != nil {
+		logger.Log.Error("autoBook", zap.Uint("scheduleID", sub.schedule.ID), zap.Error(err))
+		s.sendToUser(sub.user, fmt.Sprintf("%s 自動預約失敗：%v", s.slotText(sub.schedule), err))
+		return false
+	}
+
+	if err := s.schedule.RecordAutoBooking(ctx, &sub.schedule, group.date); err != nil {
+		logger.Log.Error("record auto booking", zap.Uint("scheduleID", sub.schedule.ID), zap.Error(err))
+	}
+	if _, err := s.booking.Record(ctx, sub.user.ID, *bookedSlot, booking.SourceAuto); err != nil {
+		logger.Log.Error("record booking", zap.Uint("scheduleID", sub.schedule.ID), zap.Error(err))
+	}
+
+	s.sendToUser(sub.user, fmt.Sprintf("%s 已自動預約成功，請前往以下網址完成付款：\n%s", s.slotText(sub.schedule), provider.GetPaymentURL()))
+	return true
+}
+
+// 場地偏好，訂閱未設定偏好時使用使用者的偏好
+func (sub subscriber) preference() types.CourtPreference {
+	pref := sub.schedule.Preference()
+	if pref.IsEmpty() {
+		pref = types.ParseCourtPreference(sub.user.CourtPreference)
+	}
+	return pref
+}
+
+// 依最高價格過濾，並依場地偏好排序
+func autoBookCandidates(sub subscriber, slots []types.Slot) []types.Slot {
+	var candidates []types.Slot
+	for _, slot := range slots {
+		// 無法取得價格的場地不自動預約
+		if sub.schedule.MaxPrice > 0 && (slot.Price == 0 || slot.Price > sub.schedule.MaxPrice) {
 			continue
 		}
-		s.tgBot.SendMessage(accountID, message)
+		candidates = append(candidates, slot)
+	}
+	return sub.preference().Apply(candidates)
+}
 
-		currentWeekday = subs.Weekday
-		currentTime = subs.TimeSlot.StartTime.Hour()
-		logger.Log.Debug("checkAllSubscriptions", zap.Uint("scheduleID", subs.ID), zap.Any("subs", subs))
+// 檢查日期是否已開放預約，各場館的開放日期每輪只查詢一次
+func (s *SchedulerService) isBookable(group *slotGroup, cache map[string][]time.Time, now time.Time) bool {
+	dates, exists := cache[group.venueID]
+	if !exists {
+		dates = s.bookableDates(group)
+		cache[group.venueID] = dates
 	}
 
+	// 無法取得開放日期時，只查詢以星期可選到的最近日期
+	if dates == nil {
+		return group.date.Equal(types.NextDateByWeekday(group.date.Weekday(), types.DateOnly(now)))
+	}
+
+	for _, date := range dates {
+		if date.Equal(group.date) {
+			return true
+		}
+	}
+	return false
+}
+
+// 取得場館開放預約的日期，不支援或查詢失敗時回傳 nil
+func (s *SchedulerService) bookableDates(group *slotGroup) []time.Time {
+	provider, err := s.providers.Get(types.VenueID(group.venueID))
+	if err != nil {
+		return nil
+	}
+
+	for _, tag := range s.crawlTags(group) {
+		dates, err := provider.GetBookableDates(tag)
+		if err == nil {
+			return dates
+		}
+		if errors.Is(err, crawler.ErrNotSupported) {
+			return nil
+		}
+		logger.Log.Warn("取得開放日期失敗，改用下一個帳號", zap.String("venue", group.venueID), zap.String("tag", tag), zap.Error(err))
+	}
 	return nil
 }
+
+// 刪除已到期的訂閱並通知使用者
+func (s *SchedulerService) expire(ctx context.Context, subs schedule.Schedule, user *user.User) {
+	if err := s.schedule.Delete(ctx, subs.ID); err != nil {
+		logger.Log.Error("delete expired schedule", zap.Uint("scheduleID", subs.ID), zap.Error(err))
+		return
+	}
+	logger.Log.Info("訂閱已到期", zap.Uint("scheduleID", subs.ID))
+
+	s.sendToUser(user, fmt.Sprintf("訂閱已到期，已自動刪除：%s", s.slotText(subs)))
+}
+
+// 發送訊息給使用者，帳號無法轉換為 TG ID 時回傳 false
+func (s *SchedulerService) sendToUser(user *user.User, message string) bool {
+	// TODO: 修改成TG帳號
+	accountID, err := strconv.ParseInt(user.AccountID, 10, 64)
+	if err != nil {
+		logger.Log.Error("invalid AccountID", zap.String("AccountID", user.AccountID), zap.Error(err))
+		return false
+	}
+	s.tgBot.SendMessage(accountID, message)
+	return true
+}
+
+// 與訂閱者上次收到通知時的場地比對，有變化時通知；同一使用者在冷卻時間內不重複通知
+// 冷卻中不更新比對基準，冷卻結束後一併通知累積的變化，新訂閱也會收到目前已空出的場地
+func (s *SchedulerService) notify(ctx context.Context, group *slotGroup, sub subscriber, slots []types.Slot) {
+	notified := sub.schedule.NotifiedCourtList(group.date)
+	baseline := make(map[string]struct{}, len(notified))
+	for _, court := range notified {
+		baseline[court] = struct{}{}
+	}
+
+	var courts, gained, lost []string
+	current := make(map[string]struct{}, len(slots))
+	for _, slot := range slots {
+		if _, duplicated := current[slot.CourtName]; duplicated {
+			continue
+		}
+		current[slot.CourtName] = struct{}{}
+		courts = append(courts, slot.CourtName)
+		if _, exists := baseline[slot.CourtName]; !exists {
+			gained = append(gained, slot.CourtName)
+		}
+	}
+	for _, court := range notified {
+		if _, exists := current[court]; !exists {
+			lost = append(lost, court)
+		}
+	}
+
+	slotText := s.slotText(sub.schedule)
+	var message string
+	switch {
+	case len(gained) > 0:
+		message = fmt.Sprintf("%s 有新的可用場地：%s", slotText, strings.Join(gained, "、"))
+	case s.notifyOnLost && len(lost) > 0:
+		message = fmt.Sprintf("%s 場地已被預約：%s", slotText, strings.Join(lost, "、"))
+	default:
+		// 不通知被預約走的場地時仍從基準移除，之後再釋出才會通知
+		if len(lost) > 0 {
+			if err := s.schedule.MarkNotified(ctx, &sub.schedule, group.date, courts); err != nil {
+				logger.Log.Error("mark notified", zap.Uint("scheduleID", sub.schedule.ID), zap.Error(err))
+			}
+		}
+		return
+	}
+
+	now := s.clock.Now()
+	if sub.user.LastNotifiedAt != nil && now.Sub(*sub.user.LastNotifiedAt) < s.notifyCooldown {
+		logger.Log.Info("通知冷卻中", zap.Uint("userID", sub.user.ID), zap.Uint("scheduleID", sub.schedule.ID))
+		return
+	}
+
+	if !s.sendToUser(sub.user, message) {
+		return
+	}
+
+	// 同一使用者的訂閱共用冷卻時間，本輪後續的訂閱也會套用
+	sub.user.LastNotifiedAt = &now
+	if err := s.user.Update(ctx, sub.user.ID, map[string]interface{}{"last_notified_at": now}); err != nil {
+		logger.Log.Error("mark user notified", zap.Uint("userID", sub.user.ID), zap.Error(err))
+	}
+	if err := s.schedule.MarkNotified(ctx, &sub.schedule, group.date, courts); err != nil {
+		logger.Log.Error("mark notified", zap.Uint("scheduleID", sub.schedule.ID), zap.Error(err))
+	}
+}
+
+// 訂閱時段文字，指定日期時顯示日期
+func (s *SchedulerService) slotText(subs schedule.Schedule) string {
+	day := "星期" + types.WeekdayName(subs.Weekday)
+	if subs.Date != nil {
+		day = subs.Date.Format("2006-01-02") + " " + day
+	}
+	return fmt.Sprintf("%s %s 時段 %d:00-%d:00",
+		s.venueName(subs.VenueID),
+		day,
+		subs.TimeSlot.StartTime.Hour(),
+		subs.TimeSlot.EndTime.Hour())
+}
+
+// 取得場館名稱
+func (s *SchedulerService) venueName(venueID string) string {
+	provider, err := s.providers.Get(types.VenueID(venueID))
+	if err != nil {
+		return venueID
+	}
+	return provider.Name()
+}
@@ -0,0 +1,247 @@
+package scheduler
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	tgbot "github.com/tian841224/crawler_sportcenter/internal/bot/tg_bot"
+	"github.com/tian841224/crawler_sportcenter/internal/crawler"
+	"github.com/tian841224/crawler_sportcenter/internal/domain/availability"
+	"github.com/tian841224/crawler_sportcenter/internal/domain/booking"
+	"github.com/tian841224/crawler_sportcenter/internal/domain/schedule"
+	timeslot "github.com/tian841224/crawler_sportcenter/internal/domain/time_slot"
+	"github.com/tian841224/crawler_sportcenter/internal/domain/user"
+	"github.com/tian841224/crawler_sportcenter/internal/types"
+	"github.com/tian841224/crawler_sportcenter/pkg/logger"
+	"go.uber.org/zap"
+)
+
+func TestMain(m *testing.M) {
+	logger.Log = zap.NewNop()
+	os.Exit(m.Run())
+}
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+type sentMessage struct {
+	chatID int64
+	text   string
+}
+
+type fakeBot struct {
+	tgbot.TGBotInterface
+	sent []sentMessage
+}
+
+func (b *fakeBot) SendMessage(chatID int64, text string) {
+	b.sent = append(b.sent, sentMessage{chatID: chatID, text: text})
+}
+
+type fakeScheduleService struct {
+	schedule.Service
+	marked map[uint]string
+}
+
+func (s *fakeScheduleService) MarkNotified(ctx context.Context, subs *schedule.Schedule, date time.Time, courts []string) error {
+	subs.NotifiedDate = &date
+	subs.NotifiedCourts = strings.Join(courts, ",")
+	s.marked[subs.ID] = subs.NotifiedCourts
+	return nil
+}
+
+func (s *fakeScheduleService) RecordAutoBooking(ctx context.Context, subs *schedule.Schedule, date time.Time) error {
+	return nil
+}
+
+type fakeBookingService struct {
+	booking.Service
+}
+
+func (s *fakeBookingService) Record(ctx context.Context, userID uint, slot types.Slot, source booking.Source) (*booking.Booking, error) {
+	return &booking.Booking{}, nil
+}
+
+// 只記錄預約，查詢場地時測試失敗，確認自動預約沿用已查到的場地
+type fakeProvider struct {
+	crawler.SportCenterProvider
+	t      *testing.T
+	booked [][]types.Slot
+	prefs  []types.CourtPreference
+}
+
+func (p *fakeProvider) ID() types.VenueID     { return types.VenueNantun }
+func (p *fakeProvider) Name() string          { return "南屯運動中心" }
+func (p *fakeProvider) GetPaymentURL() string { return "" }
+
+func (p *fakeProvider) GetAvailableTimeSlotsForSchedule(date time.Time, timeSlot int, tag string) ([]types.Slot, error) {
+	p.t.Error("auto booking must reuse the slots fetched for the group")
+	return nil, nil
+}
+
+func (p *fakeProvider) BookCourt(targetSlot []types.Slot, pref types.CourtPreference, tag string) (*types.Slot, error) {
+	p.booked = append(p.booked, targetSlot)
+	p.prefs = append(p.prefs, pref)
+	return &targetSlot[0], nil
+}
+
+type fakeUserService struct {
+	user.Service
+	updates map[uint]map[string]interface{}
+}
+
+func (s *fakeUserService) Update(ctx context.Context, id uint, updates map[string]interface{}) error {
+	s.updates[id] = updates
+	return nil
+}
+
+func newTestScheduler(clk *fakeClock, cooldown time.Duration) (*SchedulerService, *fakeBot, *fakeScheduleService) {
+	bot := &fakeBot{}
+	schedules := &fakeScheduleService{marked: make(map[uint]string)}
+	s := &SchedulerService{
+		providers:      crawler.NewProviderRegistry(),
+		schedule:       schedules,
+		user:           &fakeUserService{updates: make(map[uint]map[string]interface{})},
+		tgBot:          bot,
+		clock:          clk,
+		notifyCooldown: cooldown,
+		autoBookTried:  make(map[uint]time.Time),
+	}
+	return s, bot, schedules
+}
+
+func testSubscriber(id uint, u *user.User) subscriber {
+	start := time.Date(0, 1, 1, 18, 0, 0, 0, time.UTC)
+	return subscriber{
+		schedule: schedule.Schedule{
+			ID:       id,
+			UserID:   u.ID,
+			VenueID:  string(types.VenueNantun),
+			Weekday:  time.Tuesday,
+			TimeSlot: &timeslot.TimeSlot{StartTime: start, EndTime: start.Add(time.Hour)},
+		},
+		user: u,
+	}
+}
+
+// 模擬下一輪由資料庫重新讀取訂閱
+func reload(sub *subscriber, schedules *fakeScheduleService, date time.Time) {
+	if courts, marked := schedules.marked[sub.schedule.ID]; marked {
+		sub.schedule.NotifiedDate = &date
+		sub.schedule.NotifiedCourts = courts
+	}
+}
+
+func courtSlots(courts ...string) []types.Slot {
+	slots := make([]types.Slot, 0, len(courts))
+	for _, court := range courts {
+		slots = append(slots, types.Slot{CourtName: court})
+	}
+	return slots
+}
+
+func TestNotifyNewSubscriberGetsCurrentCourts(t *testing.T) {
+	clk := &fakeClock{now: time.Date(2026, 11, 2, 10, 0, 0, 0, time.Local)}
+	s, bot, schedules := newTestScheduler(clk, 10*time.Minute)
+	group := &slotGroup{date: time.Date(2026, 11, 3, 0, 0, 0, 0, time.Local)}
+	sub := testSubscriber(1, &user.User{ID: 1, AccountID: "100"})
+
+	s.notify(context.Background(), group, sub, courtSlots("羽球A場", "羽球B場"))
+
+	if len(bot.sent) != 1 || !strings.Contains(bot.sent[0].text, "羽球A場、羽球B場") {
+		t.Fatalf("sent = %+v, want one message with both courts", bot.sent)
+	}
+	if got := schedules.marked[1]; got != "羽球A場,羽球B場" {
+		t.Errorf("baseline = %q, want both courts", got)
+	}
+}
+
+func TestNotifyCooldownIsPerUser(t *testing.T) {
+	clk := &fakeClock{now: time.Date(2026, 11, 2, 10, 0, 0, 0, time.Local)}
+	s, bot, schedules := newTestScheduler(clk, 10*time.Minute)
+	group := &slotGroup{date: time.Date(2026, 11, 3, 0, 0, 0, 0, time.Local)}
+	u := &user.User{ID: 1, AccountID: "100"}
+	first, second := testSubscriber(1, u), testSubscriber(2, u)
+
+	s.notify(context.Background(), group, first, courtSlots("羽球A場"))
+	s.notify(context.Background(), group, second, courtSlots("羽球A場"))
+
+	if len(bot.sent) != 1 {
+		t.Fatalf("sent %d messages, want 1 while the user is cooling down", len(bot.sent))
+	}
+	if _, marked := schedules.marked[2]; marked {
+		t.Error("suppressed subscription must keep its baseline")
+	}
+
+	// 冷卻結束後通知累積的變化
+	clk.now = clk.now.Add(11 * time.Minute)
+	s.notify(context.Background(), group, second, courtSlots("羽球A場", "羽球C場"))
+	if len(bot.sent) != 2 || !strings.Contains(bot.sent[1].text, "羽球A場、羽球C場") {
+		t.Fatalf("sent = %+v, want accumulated courts after cooldown", bot.sent)
+	}
+}
+
+func TestNotifyDropsLostCourtsFromBaseline(t *testing.T) {
+	clk := &fakeClock{now: time.Date(2026, 11, 2, 10, 0, 0, 0, time.Local)}
+	s, bot, schedules := newTestScheduler(clk, 0)
+	group := &slotGroup{date: time.Date(2026, 11, 3, 0, 0, 0, 0, time.Local)}
+	sub := testSubscriber(1, &user.User{ID: 1, AccountID: "100"})
+
+	s.notify(context.Background(), group, sub, courtSlots("羽球A場", "羽球B場"))
+	reload(&sub, schedules, group.date)
+	s.notify(context.Background(), group, sub, courtSlots("羽球A場"))
+	if len(bot.sent) != 1 {
+		t.Fatalf("sent %d messages, want no message for lost courts", len(bot.sent))
+	}
+	if got := schedules.marked[1]; got != "羽球A場" {
+		t.Errorf("baseline = %q, want lost court removed", got)
+	}
+
+	reload(&sub, schedules, group.date)
+	s.notify(context.Background(), group, sub, courtSlots("羽球A場", "羽球B場"))
+	if len(bot.sent) != 2 || !strings.Contains(bot.sent[1].text, "羽球B場") {
+		t.Fatalf("sent = %+v, want released court notified again", bot.sent)
+	}
+}
+
+func TestAutoBookReusesFetchedSlotsOncePerDate(t *testing.T) {
+	clk := &fakeClock{now: time.Date(2026, 11, 2, 10, 0, 0, 0, time.Local)}
+	s, _, _ := newTestScheduler(clk, 0)
+	provider := &fakeProvider{t: t}
+	s.providers = crawler.NewProviderRegistry(provider)
+	s.booking = &fakeBookingService{}
+
+	group := &slotGroup{venueID: string(types.VenueNantun), date: time.Date(2026, 11, 3, 0, 0, 0, 0, time.Local)}
+	sub := testSubscriber(1, &user.User{ID: 1, AccountID: "100", CourtPreference: "羽球A場 !羽球F場"})
+	sub.schedule.AutoBook = true
+	slots := courtSlots("羽球A場")
+
+	noChange := &availability.Change{}
+	if !s.shouldAutoBook(group, sub, slots, noChange) {
+		t.Fatal("first attempt for the date must be allowed")
+	}
+	if !s.autoBook(context.Background(), group, sub, slots) {
+		t.Fatal("autoBook failed")
+	}
+	if len(provider.booked) != 1 || provider.booked[0][0].CourtName != "羽球A場" {
+		t.Fatalf("booked = %+v, want the fetched court", provider.booked)
+	}
+	if got := provider.prefs[0].String(); got != "羽球A場 !羽球F場" {
+		t.Errorf("preference = %q, want the user's stored preference", got)
+	}
+
+	if s.shouldAutoBook(group, sub, slots, noChange) {
+		t.Error("must not retry the same date without newly gained courts")
+	}
+	if !s.shouldAutoBook(group, sub, slots, &availability.Change{Gained: []string{"羽球A場"}}) {
+		t.Error("must retry when courts are gained")
+	}
+}
@@ -0,0 +1,98 @@
+package types
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// CourtPreference 場地偏好，Ranked 依序優先，Excluded 不預約
+// 文字格式例如 "羽球A場>羽球C場 !羽球F場"
+type CourtPreference struct {
+	Ranked   []string
+	Excluded []string
+}
+
+// ParseCourtPreference 解析場地偏好，場地以 >、逗號或空白分隔，! 開頭表示排除
+func ParseCourtPreference(value string) CourtPreference {
+	var pref CourtPreference
+	fields := strings.FieldsFunc(value, func(r rune) bool {
+		return r == '>' || r == ',' || r == '，' || r == ' ' || r == '\t'
+	})
+	for _, field := range fields {
+		if name, excluded := strings.CutPrefix(field, "!"); excluded {
+			if name != "" {
+				pref.Excluded = append(pref.Excluded, name)
+			}
+			continue
+		}
+		pref.Ranked = append(pref.Ranked, field)
+	}
+	return pref
+}
+
+// String 轉回文字格式，可直接儲存
+func (p CourtPreference) String() string {
+	parts := []string{}
+	if len(p.Ranked) > 0 {
+		parts = append(parts, strings.Join(p.Ranked, ">"))
+	}
+	for _, name := range p.Excluded {
+		parts = append(parts, "!"+name)
+	}
+	return strings.Join(parts, " ")
+}
+
+// IsEmpty 未設定任何偏好
+func (p CourtPreference) IsEmpty() bool {
+	return len(p.Ranked) == 0 && len(p.Excluded) == 0
+}
+
+// Allows 場地是否未被排除
+func (p CourtPreference) Allows(court string) bool {
+	for _, name := range p.Excluded {
+		if matchCourt(court, name) {
+			return false
+		}
+	}
+	return true
+}
+
+// Rank 場地的優先順序，未列出的場地排在最後
+func (p CourtPreference) Rank(court string) int {
+	for i, name := range p.Ranked {
+		if matchCourt(court, name) {
+			return i
+		}
+	}
+	return len(p.Ranked)
+}
+
+// Apply 移除排除的場地，並依偏好排序（未列出的場地維持原順序）
+func (p CourtPreference) Apply(slots []Slot) []Slot {
+	result := make([]Slot, 0, len(slots))
+	for _, slot := range slots {
+		if p.Allows(slot.CourtName) {
+			result = append(result, slot)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return p.Rank(result[i].CourtName) < p.Rank(result[j].CourtName)
+	})
+	return result
+}
+
+// 場地名稱相同，或以偏好名稱開頭（例如 "羽球F" 符合 "羽球F場"）
+// 名稱後接數字時視為不同場地，例如 "羽球1" 不符合 "羽球10場"
+func matchCourt(court, name string) bool {
+	if court == name {
+		return true
+	}
+	rest, ok := strings.CutPrefix(court, name)
+	if !ok {
+		return false
+	}
+	next, _ := utf8.DecodeRuneInString(rest)
+	return !unicode.IsDigit(next)
+}
@@ -0,0 +1,41 @@
+package types
+
+import "testing"
+
+func TestMatchCourt(t *testing.T) {
+	tests := []struct {
+		court string
+		name  string
+		want  bool
+	}{
+		{"羽球F場", "羽球F場", true},
+		{"羽球F場", "羽球F", true},
+		{"羽球1", "羽球1", true},
+		{"羽球1場", "羽球1", true},
+		{"羽球10場", "羽球1", false},
+		{"羽球11", "羽球1", false},
+		{"羽球A場", "羽球B", false},
+	}
+
+	for _, tt := range tests {
+		if got := matchCourt(tt.court, tt.name); got != tt.want {
+			t.Errorf("matchCourt(%q, %q) = %v, want %v", tt.court, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCourtPreferenceApplyDoesNotMatchLongerNumbers(t *testing.T) {
+	pref := CourtPreference{Ranked: []string{"羽球1"}, Excluded: []string{"羽球2"}}
+	slots := []Slot{{CourtName: "羽球10"}, {CourtName: "羽球20"}, {CourtName: "羽球1"}, {CourtName: "羽球2"}}
+
+	got := pref.Apply(slots)
+	want := []string{"羽球1", "羽球10", "羽球20"}
+	if len(got) != len(want) {
+		t.Fatalf("got %+v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i].CourtName != want[i] {
+			t.Errorf("slot %d = %s, want %s", i, got[i].CourtName, want[i])
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DateLayout 日期格式
+const DateLayout = "2006-01-02"
+
+// ParseDate 解析使用者輸入的日期，支援 2026-11-03 與 2026/11/03
+func ParseDate(value string) (time.Time, error) {
+	value = strings.ReplaceAll(strings.TrimSpace(value), "/", "-")
+	date, err := time.ParseInLocation(DateLayout, value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("無效的日期: %s", value)
+	}
+	return date, nil
+}
+
+// DateOnly 去除時間部分，只保留本地日期
+func DateOnly(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
@@ -0,0 +1,58 @@
+package types
+
+import (
+	"strings"
+	"time"
+)
+
+// OrderStatus 會員訂單的付款狀態
+type OrderStatus int
+
+const (
+	OrderUnknown   OrderStatus = iota // 無法辨識的狀態文字
+	OrderUnpaid                       // 待付款
+	OrderPaid                         // 已付款
+	OrderCancelled                    // 已取消
+	OrderExpired                      // 逾期未付款，已由網站取消
+)
+
+// Order 運動中心會員訂單
+type Order struct {
+	OrderID         string      `json:"orderId,omitempty"`
+	CourtName       string      `json:"courtName"`
+	Start           time.Time   `json:"start"`
+	End             time.Time   `json:"end"`
+	Price           int         `json:"price"`
+	Status          OrderStatus `json:"status"`
+	PaymentDeadline time.Time   `json:"paymentDeadline"` // 零值表示頁面未提供
+}
+
+// Date 訂單的使用日期
+func (o Order) Date() time.Time {
+	return DateOnly(o.Start)
+}
+
+// Active 訂單是否仍有效（待付款或已付款）
+func (o Order) Active() bool {
+	return o.Status == OrderUnpaid || o.Status == OrderPaid
+}
+
+// SameAs 是否為同一筆訂單
+// 兩邊都有訂單編號時比對編號，否則比對日期、開始時間與場地名稱（未知的欄位略過）
+func (o Order) SameAs(other Order) bool {
+	if o.OrderID != "" && other.OrderID != "" {
+		return o.OrderID == other.OrderID
+	}
+	if !o.Date().Equal(other.Date()) {
+		return false
+	}
+	if o.Start.Hour() != 0 && other.Start.Hour() != 0 &&
+		(o.Start.Hour() != other.Start.Hour() || o.Start.Minute() != other.Start.Minute()) {
+		return false
+	}
+	if o.CourtName != "" && other.CourtName != "" &&
+		!strings.HasPrefix(o.CourtName, other.CourtName) && !strings.HasPrefix(other.CourtName, o.CourtName) {
+		return false
+	}
+	return true
+}
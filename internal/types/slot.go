@@ -0,0 +1,113 @@
+package types
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BookingParams 預約按鈕的參數
+// 南屯為 DoSubmit2(場地, 日期, 時段, 價格)，朝馬為 Step3Action(場地, 時段) 加上查詢日期
+type BookingParams struct {
+	CourtID int       `json:"courtId"`
+	Date    time.Time `json:"date"`
+	Period  int       `json:"period"`
+	Price   int       `json:"price,omitempty"`
+}
+
+// String 以逗號串接參數，用於回呼資料，例如 84,2025-05-13,6,250
+func (p BookingParams) String() string {
+	return fmt.Sprintf("%d,%s,%d,%d", p.CourtID, p.Date.Format(DateLayout), p.Period, p.Price)
+}
+
+// ParseBookingParams 解析 String 產生的參數
+func ParseBookingParams(value string) (BookingParams, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 4 {
+		return BookingParams{}, fmt.Errorf("無效的預約參數: %s", value)
+	}
+
+	var params BookingParams
+	var err error
+	if params.CourtID, err = strconv.Atoi(parts[0]); err != nil {
+		return BookingParams{}, fmt.Errorf("無效的預約參數: %s", value)
+	}
+	if params.Date, err = ParseDate(parts[1]); err != nil {
+		return BookingParams{}, err
+	}
+	if params.Period, err = strconv.Atoi(parts[2]); err != nil {
+		return BookingParams{}, fmt.Errorf("無效的預約參數: %s", value)
+	}
+	if params.Price, err = strconv.Atoi(parts[3]); err != nil {
+		return BookingParams{}, fmt.Errorf("無效的預約參數: %s", value)
+	}
+	return params, nil
+}
+
+// Slot 可預約的場地時段
+type Slot struct {
+	VenueID   VenueID       `json:"venueId"`
+	CourtID   int           `json:"courtId"`
+	CourtName string        `json:"courtName"`
+	Start     time.Time     `json:"start"`
+	End       time.Time     `json:"end"`
+	Price     int           `json:"price"` // 0 表示無法取得價格
+	Params    BookingParams `json:"params"`
+}
+
+// Date 時段所在日期
+func (s Slot) Date() time.Time {
+	return DateOnly(s.Start)
+}
+
+// Matches 是否為指定的時段代碼
+func (s Slot) Matches(code TimeSlotCode) bool {
+	return !s.Start.IsZero() && s.Start.Hour() == code.StartHour()
+}
+
+// TimeRange 時間範圍，例如 19:00-20:00
+func (s Slot) TimeRange() string {
+	return fmt.Sprintf("%s-%s", s.Start.Format("15:04"), s.End.Format("15:04"))
+}
+
+var priceDigits = regexp.MustCompile(`\d+`)
+
+// ParsePrice 解析價格文字，例如 "250元"，無法解析時回傳 0
+func ParsePrice(value string) int {
+	price, err := strconv.Atoi(priceDigits.FindString(strings.ReplaceAll(value, ",", "")))
+	if err != nil {
+		return 0
+	}
+	return price
+}
+
+// 網站上的時間可能使用全形冒號或不同的分隔符號，例如 19：00-20：00、20:00~21:00
+var timeRangeReplacer = strings.NewReplacer("：", ":", "～", "-", "~", "-", "至", "-", " ", "")
+
+// ParseTimeRange 解析時間範圍文字，回傳指定日期的開始與結束時間
+func ParseTimeRange(date time.Time, value string) (time.Time, time.Time, error) {
+	start, end, found := strings.Cut(timeRangeReplacer.Replace(value), "-")
+	if !found {
+		return time.Time{}, time.Time{}, fmt.Errorf("無效的時間範圍: %s", value)
+	}
+
+	startTime, err := parseClock(date, start)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("無效的時間範圍: %s", value)
+	}
+	endTime, err := parseClock(date, end)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("無效的時間範圍: %s", value)
+	}
+	return startTime, endTime, nil
+}
+
+func parseClock(date time.Time, value string) (time.Time, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return DateOnly(date).Add(time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute), nil
+}
@@ -0,0 +1,15 @@
+package types
+
+// VenueID 運動中心代碼
+type VenueID string
+
+const (
+	VenueNantun VenueID = "nantun"  // 南屯運動中心
+	VenueChaoMa VenueID = "chao_ma" // 朝馬運動中心
+)
+
+// Facility 運動中心提供的場地類型
+type Facility struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
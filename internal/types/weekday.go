@@ -0,0 +1,17 @@
+package types
+
+import "time"
+
+// WeekdayNames 星期對應的中文名稱，索引與 time.Weekday 相同
+var WeekdayNames = []string{"日", "一", "二", "三", "四", "五", "六"}
+
+// WeekdayName 取得星期的中文名稱
+func WeekdayName(weekday time.Weekday) string {
+	return WeekdayNames[int(weekday)%len(WeekdayNames)]
+}
+
+// NextDateByWeekday 取得 from 起最近一個符合星期的日期（含當天）
+func NextDateByWeekday(weekday time.Weekday, from time.Time) time.Time {
+	offset := (int(weekday) - int(from.Weekday()) + 7) % 7
+	return from.AddDate(0, 0, offset)
+}
@@ -24,10 +24,36 @@ type Config struct {
 	TimeSlotCodes         []types.TimeSlotCode // 改為切片以支援多個時段
 	DayPeriod             int
 	ButtonIndex           []int
+	CourtPreference       string // 預約時的場地偏好，例如 羽球A場>羽球C場 !羽球F場
 	ID                    string
 	Password              string
+	AdminAccountID        string   // 管理員 Telegram ID，可使用設定檔的帳密
+	SecretKey             string   // 加密運動中心密碼的金鑰（base64 32 bytes）
+	PreviousSecretKeys    []string // 輪替前的舊金鑰，僅用於解密
 	TG_Bot_Token          string
 	TG_Bot_Webhook_Domain string
+	SessionStore          string // Bot 對話狀態儲存方式：db 或 memory
+	SessionTTL            int    // Bot 對話狀態保存分鐘數
+	NotifyCooldown        int    // 同一使用者兩次通知的最短間隔分鐘數
+	NotifyOnLost          bool   // 場地被預約走時是否通知
+	PaymentCheckInterval  int    // 檢查付款狀態的間隔分鐘數，0 表示停用
+	PaymentReminders      []int  // 繳費期限前幾分鐘提醒
+	KeepAliveInterval     int    // 為訂閱者保持網站登入的間隔分鐘數，0 表示停用
+	NantunHTTP            bool   // 南屯以 HTTP 查詢，失敗時才使用瀏覽器，預設關閉
+	BrowserMaxPages       int    // 瀏覽器同時開啟的分頁上限
+	BrowserIdleTimeout    int    // 分頁閒置超過幾分鐘後關閉，0 表示不關閉
+	BrowserHeadless       bool   // 瀏覽器無頭模式
+	BrowserBin            string // 瀏覽器執行檔路徑
+	BrowserUserDataDir    string // 瀏覽器使用者資料目錄
+	BrowserProxy          string // 瀏覽器代理伺服器
+	BrowserControlURL     string // 連線至已啟動瀏覽器的 DevTools 網址
+	BrowserManagerURL     string // rod manager 網址
+	SniperEnabled         bool   // 是否啟用開放時間搶場
+	SniperOpenTime        string // 開放預約時間，格式 15:04:05
+	SniperPrepareSeconds  int    // 開放前幾秒登入並前往預約頁
+	SniperRetries         int    // 開放後重試次數
+	SniperRetryInterval   int    // 重試間隔毫秒數
+	SniperDryRun          bool   // 只找出要預約的場地，不送出預約
 	// TG_Bot_Webhook_Port   string
 	// TG_Bot_Secret_Token string
 }
@@ -80,6 +106,12 @@ func LoadConfig() Config {
 		timeSlotCodes = append(timeSlotCodes, types.TimeSlotCode(1))
 	}
 
+	// 範例設定檔使用 PASSWORD，舊版讀取 Password，未設定 PASSWORD 時沿用
+	password := os.Getenv("PASSWORD")
+	if password == "" {
+		password = os.Getenv("Password")
+	}
+
 	// 從環境變數中獲取值
 	cfg := Config{
 		DBType:                os.Getenv("DB_TYPE"),
@@ -90,11 +122,25 @@ func LoadConfig() Config {
 		DBUser:                os.Getenv("DB_USER"),
 		DBPassword:            os.Getenv("DB_PASSWORD"),
 		ChooseWeekday:         os.Getenv("CHOOSE_WEEKDAY"),
+		CourtPreference:       os.Getenv("COURT_PREFERENCE"),
 		TimeSlotCodes:         timeSlotCodes,
 		ID:                    os.Getenv("ID"),
-		Password:              os.Getenv("Password"),
+		Password:              password,
+		AdminAccountID:        os.Getenv("ADMIN_TG_ID"),
+		SecretKey:             os.Getenv("SECRET_KEY"),
 		TG_Bot_Token:          os.Getenv("TELEGRAM_BOT_TOKEN"),
 		TG_Bot_Webhook_Domain: os.Getenv("TELEGRAM_BOT_WEBHOOK_DOMAIN"),
+		SessionStore:          os.Getenv("SESSION_STORE"),
+		NotifyOnLost:          os.Getenv("NOTIFY_ON_LOST") == "true",
+		NantunHTTP:            os.Getenv("NANTUN_HTTP") == "true",
+		BrowserHeadless:       os.Getenv("BROWSER_HEADLESS") == "true",
+		BrowserBin:            os.Getenv("BROWSER_BIN"),
+		BrowserUserDataDir:    os.Getenv("BROWSER_USER_DATA_DIR"),
+		BrowserProxy:          os.Getenv("BROWSER_PROXY"),
+		BrowserControlURL:     os.Getenv("BROWSER_CONTROL_URL"),
+		BrowserManagerURL:     os.Getenv("BROWSER_MANAGER_URL"),
+		SniperEnabled:         os.Getenv("SNIPER_ENABLED") == "true",
+		SniperDryRun:          os.Getenv("SNIPER_DRY_RUN") == "true",
 		// TG_Bot_Webhook_Port:   os.Getenv("TG_Bot_Webhook_Port"),
 		// TG_Bot_Secret_Token: os.Getenv("TELEGRAM_BOT_SECRET_TOKEN"),
 		DayPeriod: func() int {
@@ -123,6 +169,98 @@ func LoadConfig() Config {
 			}
 			return indexArray
 		}(),
+		SessionTTL: func() int {
+			ttl, err := strconv.Atoi(os.Getenv("SESSION_TTL"))
+			if err != nil || ttl <= 0 {
+				return 30
+			}
+			return ttl
+		}(),
+		NotifyCooldown: func() int {
+			cooldown, err := strconv.Atoi(os.Getenv("NOTIFY_COOLDOWN"))
+			if err != nil || cooldown < 0 {
+				return 30
+			}
+			return cooldown
+		}(),
+		PaymentCheckInterval: func() int {
+			interval, err := strconv.Atoi(os.Getenv("PAYMENT_CHECK_INTERVAL"))
+			if err != nil || interval < 0 {
+				return 10
+			}
+			return interval
+		}(),
+		KeepAliveInterval: func() int {
+			interval, err := strconv.Atoi(os.Getenv("KEEPALIVE_INTERVAL"))
+			if err != nil || interval < 0 {
+				return 10
+			}
+			return interval
+		}(),
+		PaymentReminders: func() []int {
+			remindersStr := os.Getenv("PAYMENT_REMINDERS")
+			if remindersStr == "" {
+				return []int{360, 60}
+			}
+
+			reminders := []int{}
+			for _, str := range strings.Split(remindersStr, ",") {
+				minutes, err := strconv.Atoi(strings.TrimSpace(str))
+				if err == nil && minutes > 0 {
+					reminders = append(reminders, minutes)
+				}
+			}
+			return reminders
+		}(),
+		BrowserMaxPages: func() int {
+			pages, err := strconv.Atoi(os.Getenv("BROWSER_MAX_PAGES"))
+			if err != nil || pages <= 0 {
+				return 6
+			}
+			return pages
+		}(),
+		BrowserIdleTimeout: func() int {
+			timeout, err := strconv.Atoi(os.Getenv("BROWSER_IDLE_TIMEOUT"))
+			if err != nil || timeout < 0 {
+				return 30
+			}
+			return timeout
+		}(),
+		SniperOpenTime: func() string {
+			openTime := os.Getenv("SNIPER_OPEN_TIME")
+			if openTime == "" {
+				return "13:00:00"
+			}
+			return openTime
+		}(),
+		SniperPrepareSeconds: func() int {
+			seconds, err := strconv.Atoi(os.Getenv("SNIPER_PREPARE_SECONDS"))
+			if err != nil || seconds <= 0 {
+				return 60
+			}
+			return seconds
+		}(),
+		SniperRetries: func() int {
+			retries, err := strconv.Atoi(os.Getenv("SNIPER_RETRIES"))
+			if err != nil || retries <= 0 {
+				return 5
+			}
+			return retries
+		}(),
+		SniperRetryInterval: func() int {
+			interval, err := strconv.Atoi(os.Getenv("SNIPER_RETRY_INTERVAL"))
+			if err != nil || interval <= 0 {
+				return 300
+			}
+			return interval
+		}(),
+		PreviousSecretKeys: func() []string {
+			keysStr := os.Getenv("SECRET_KEY_PREVIOUS")
+			if keysStr == "" {
+				return []string{}
+			}
+			return strings.Split(keysStr, ",")
+		}(),
 	}
 
 	return cfg
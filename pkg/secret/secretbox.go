@@ -0,0 +1,120 @@
+package secret
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+// 加密後字串格式為 enc:v1:<金鑰代碼>:<base64(nonce+密文)>
+const prefix = "enc:v1:"
+
+var (
+	ErrInvalidKey        = errors.New("金鑰必須為 base64 編碼的 32 bytes")
+	ErrUnknownKey        = errors.New("找不到對應的解密金鑰")
+	ErrInvalidCiphertext = errors.New("密文格式錯誤")
+)
+
+// Box 以 NaCl secretbox 加解密字串，支援以舊金鑰解密以便輪替
+type Box struct {
+	currentID string
+	keys      map[string]*[32]byte
+}
+
+// NewBox 建立加解密器，current 用於加密，previous 僅用於解密舊資料
+func NewBox(current string, previous ...string) (*Box, error) {
+	b := &Box{keys: make(map[string]*[32]byte)}
+
+	id, err := b.addKey(current)
+	if err != nil {
+		return nil, fmt.Errorf("current key: %w", err)
+	}
+	b.currentID = id
+
+	for _, key := range previous {
+		if strings.TrimSpace(key) == "" {
+			continue
+		}
+		if _, err := b.addKey(key); err != nil {
+			return nil, fmt.Errorf("previous key: %w", err)
+		}
+	}
+	return b, nil
+}
+
+// Encrypt 以目前金鑰加密
+func (b *Box) Encrypt(plaintext string) (string, error) {
+	var nonce [24]byte
+	if _, err := io.ReadFull(rand.Reader, nonce[:]); err != nil {
+		return "", fmt.Errorf("產生 nonce 失敗: %w", err)
+	}
+
+	sealed := secretbox.Seal(nonce[:], []byte(plaintext), &nonce, b.keys[b.currentID])
+	return prefix + b.currentID + ":" + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt 解密字串，未加密的舊資料會原樣回傳
+func (b *Box) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+
+	id, payload, found := strings.Cut(strings.TrimPrefix(value, prefix), ":")
+	if !found {
+		return "", ErrInvalidCiphertext
+	}
+
+	key, exists := b.keys[id]
+	if !exists {
+		return "", ErrUnknownKey
+	}
+
+	sealed, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil || len(sealed) < 24+secretbox.Overhead {
+		return "", ErrInvalidCiphertext
+	}
+
+	var nonce [24]byte
+	copy(nonce[:], sealed[:24])
+	plaintext, ok := secretbox.Open(nil, sealed[24:], &nonce, key)
+	if !ok {
+		return "", ErrInvalidCiphertext
+	}
+	return string(plaintext), nil
+}
+
+// NeedsRotation 未加密或非使用目前金鑰加密時回傳 true
+func (b *Box) NeedsRotation(value string) bool {
+	if !IsEncrypted(value) {
+		return true
+	}
+	return !strings.HasPrefix(value, prefix+b.currentID+":")
+}
+
+// IsEncrypted 判斷字串是否為加密格式
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+func (b *Box) addKey(encoded string) (string, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil || len(raw) != 32 {
+		return "", ErrInvalidKey
+	}
+
+	var key [32]byte
+	copy(key[:], raw)
+
+	// 以金鑰雜湊前 8 碼作為代碼，不需另外設定
+	sum := sha256.Sum256(key[:])
+	id := hex.EncodeToString(sum[:4])
+	b.keys[id] = &key
+	return id, nil
+}